@@ -0,0 +1,322 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	cmdlib "github.com/internal-hackathon-7/int-hack-7/agent/lib/cmd"
+	outbox "github.com/internal-hackathon-7/int-hack-7/agent/lib/outbox"
+)
+
+// The control API is one JSON request per connection on a unix socket:
+//
+//	{"op": "status"}
+//
+// answered by one or more JSON lines of ControlResponse. Ops are status,
+// snapshot, pause, resume, reload, flush and tail. A supervisor serves every
+// project it monitors: Project picks one, and an empty Project means all.
+
+// ControlRequest is a request to the control API
+type ControlRequest struct {
+	Op      string `json:"op"`
+	Project string `json:"project,omitempty"`
+	// N is how many recent events tail replays, default defaultTailEvents
+	N int `json:"n,omitempty"`
+	// Follow keeps a tail streaming new events until the client disconnects
+	Follow bool `json:"follow,omitempty"`
+	// Timeout bounds flush, like "10s"; default flushWaitTimeout
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// ControlResponse is one line of a control API reply; tail sends one per event
+type ControlResponse struct {
+	OK     bool   `json:"ok"`
+	Error  string `json:"error,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+// ProjectStatus is a project's entry in the status result
+type ProjectStatus struct {
+	Project string `json:"project"`
+	agentStatus
+	OutboxPending int `json:"outbox_pending"`
+}
+
+// StatusResult is the result of the status op
+type StatusResult struct {
+	PID        int             `json:"pid"`
+	Supervisor bool            `json:"supervisor,omitempty"`
+	Projects   []ProjectStatus `json:"projects"`
+}
+
+// FlushResult is the result of the flush op
+type FlushResult struct {
+	Pending int `json:"pending"`
+}
+
+const (
+	defaultTailEvents  = 20
+	flushWaitTimeout   = 10 * time.Second
+	controlReadTimeout = 5 * time.Second
+)
+
+// controlSocketPath is the project agent's socket. It lives with the hook
+// sockets rather than in .daemon, where a deep project would push the path
+// past the unix socket length limit.
+func controlSocketPath(projectPath string) (string, error) {
+	return cmdlib.ProjectSocketPath("control", projectPath)
+}
+
+func supervisorSocketPath() (string, error) {
+	dir, err := userDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "supervisor.sock"), nil
+}
+
+// controlServer answers control API requests for the services it is given
+type controlServer struct {
+	ln   net.Listener
+	path string
+
+	ob         *outbox.Outbox
+	events     *eventLog
+	supervisor bool
+	// services returns the running services
+	services func() []*service
+}
+
+// listenControl serves c on a unix socket at path. The caller holds the PID
+// file, so a socket already there was left by a crashed agent.
+func listenControl(path string, c *controlServer) (*controlServer, error) {
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// anyone who can connect can pause the agent
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict %s: %w", path, err)
+	}
+
+	c.ln, c.path = ln, path
+	go c.serve()
+	return c, nil
+}
+
+func (c *controlServer) Close() error {
+	err := c.ln.Close()
+	os.Remove(c.path)
+	return err
+}
+
+func (c *controlServer) serve() {
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+		go c.handle(conn)
+	}
+}
+
+func (c *controlServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req ControlRequest
+	conn.SetReadDeadline(time.Now().Add(controlReadTimeout))
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		writeResponse(conn, nil, fmt.Errorf("bad request: %w", err))
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	if req.Op == "tail" {
+		c.tail(conn, req)
+		return
+	}
+	result, err := c.do(context.Background(), req)
+	writeResponse(conn, result, err)
+}
+
+func (c *controlServer) do(ctx context.Context, req ControlRequest) (any, error) {
+	switch req.Op {
+	case "status":
+		return c.status(req)
+
+	case "snapshot", "pause", "resume", "reload":
+		targets, err := c.targets(req.Project)
+		if err != nil {
+			return nil, err
+		}
+		var errs []error
+		for _, svc := range targets {
+			if err := svc.call(ctx, req.Op); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", svc.project, err))
+			}
+		}
+		return nil, errors.Join(errs...)
+
+	case "flush":
+		timeout := flushWaitTimeout
+		if req.Timeout != "" {
+			d, err := time.ParseDuration(req.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timeout: %w", err)
+			}
+			timeout = d
+		}
+		return c.flush(ctx, timeout)
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", req.Op)
+}
+
+// targets returns the service for project, or all of them when it is empty
+func (c *controlServer) targets(project string) ([]*service, error) {
+	services := c.services()
+	if project == "" {
+		return services, nil
+	}
+
+	abs, err := filepath.Abs(project)
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		if svc.project == abs {
+			return []*service{svc}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not monitored here", abs)
+}
+
+func (c *controlServer) status(req ControlRequest) (StatusResult, error) {
+	res := StatusResult{PID: os.Getpid(), Supervisor: c.supervisor, Projects: []ProjectStatus{}}
+
+	targets, err := c.targets(req.Project)
+	if err != nil {
+		return res, err
+	}
+
+	// there is one outbox per process, shared by a supervisor's projects
+	pending, err := c.ob.Depth()
+	if err != nil {
+		return res, fmt.Errorf("outbox: %w", err)
+	}
+	for _, svc := range targets {
+		res.Projects = append(res.Projects, ProjectStatus{
+			Project:       svc.project,
+			agentStatus:   svc.status.get(),
+			OutboxPending: pending,
+		})
+	}
+	return res, nil
+}
+
+// flush cuts the outbox's backoff short and waits for it to drain
+func (c *controlServer) flush(ctx context.Context, timeout time.Duration) (FlushResult, error) {
+	c.ob.Kick()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		pending, err := c.ob.Depth()
+		if err != nil {
+			return FlushResult{}, fmt.Errorf("outbox: %w", err)
+		}
+		if pending == 0 {
+			return FlushResult{}, nil
+		}
+		if time.Now().After(deadline) {
+			return FlushResult{Pending: pending}, fmt.Errorf("%d records still pending after %s", pending, timeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return FlushResult{Pending: pending}, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// tail replays recent events and, with Follow, streams new ones
+func (c *controlServer) tail(conn net.Conn, req ControlRequest) {
+	project := ""
+	if req.Project != "" {
+		targets, err := c.targets(req.Project)
+		if err != nil {
+			writeResponse(conn, nil, err)
+			return
+		}
+		project = targets[0].project
+	}
+	n := req.N
+	if n <= 0 {
+		n = defaultTailEvents
+	}
+
+	// follow before replaying so nothing falls between the two
+	var live <-chan Event
+	if req.Follow {
+		ch, stop := c.events.follow()
+		defer stop()
+		live = ch
+	}
+
+	for _, ev := range c.events.recent(project, n) {
+		if writeResponse(conn, ev, nil) != nil {
+			return
+		}
+	}
+	if !req.Follow {
+		return
+	}
+
+	// the client sends nothing more; a read returns when it hangs up
+	gone := make(chan struct{})
+	go func() {
+		var b [1]byte
+		conn.Read(b[:])
+		close(gone)
+	}()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case ev := <-live:
+			if project != "" && ev.Project != "" && ev.Project != project {
+				continue
+			}
+			if writeResponse(conn, ev, nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeResponse(conn net.Conn, result any, err error) error {
+	resp := ControlResponse{OK: err == nil, Result: result}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return json.NewEncoder(conn).Encode(resp)
+}
+
+// uploadEvent records a delivery from the outbox
+func uploadEvent(events *eventLog, project, kind string, err error) {
+	if err != nil {
+		events.add(project, "upload_failed", "%s: %v", kind, err)
+		return
+	}
+	events.add(project, "upload", "%s", kind)
+}
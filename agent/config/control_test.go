@@ -0,0 +1,37 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a project nested deeper than the unix socket length limit still gets a
+// control socket it can listen on
+func TestControlSocketForDeepProject(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	project := filepath.Join(t.TempDir(), strings.Repeat("nested-directory/", 10))
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sock, err := controlSocketPath(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := controlSocketPath(project + "/"); other != sock {
+		t.Errorf("same project, different sockets: %s and %s", sock, other)
+	}
+
+	ctl, err := listenControl(sock, &controlServer{services: func() []*service { return nil }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctl.Close()
+
+	if err := callAgent(sock, ControlRequest{Op: "nope"}, nil); err == nil || !strings.Contains(err.Error(), "unknown operation") {
+		t.Fatalf("callAgent = %v, want the server's unknown operation error", err)
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// controlTarget finds the socket serving projectPath: the project's own
+// agent, or the supervisor with the project to ask it about. With
+// supervisor set it is the supervisor, about every project.
+func controlTarget(projectPath string, supervisor bool) (sock, project string, err error) {
+	if supervisor {
+		sock, err := supervisorSocketPath()
+		return sock, "", err
+	}
+
+	abs, err := filepath.Abs(projectPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve project path: %w", err)
+	}
+	if pid, running, err := agentRunning(abs); err == nil && running && supervisedBy(abs, pid) {
+		sock, err := supervisorSocketPath()
+		return sock, abs, err
+	}
+	sock, err = controlSocketPath(abs)
+	return sock, "", err
+}
+
+// callAgent sends req to the control socket and hands each result line to
+// each, which may be nil. An error reply is returned as an error.
+func callAgent(sock string, req ControlRequest, each func(json.RawMessage) error) error {
+	conn, err := net.DialTimeout("unix", sock, time.Second)
+	if err != nil {
+		return fmt.Errorf("no agent is listening on %s: %w", sock, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var resp struct {
+			OK     bool            `json:"ok"`
+			Error  string          `json:"error"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := dec.Decode(&resp); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("bad reply from agent: %w", err)
+		}
+
+		if !resp.OK {
+			return errors.New(resp.Error)
+		}
+		if each != nil && len(resp.Result) > 0 {
+			if err := each(resp.Result); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// decodeInto is a callAgent callback storing the result in out
+func decodeInto(out any) func(json.RawMessage) error {
+	return func(raw json.RawMessage) error {
+		return json.Unmarshal(raw, out)
+	}
+}
+
+// ControlCommand handles `daemon snapshot|pause|resume|reload|flush`, sent
+// to the running agent over its control socket
+func ControlCommand(op string, args []string) error {
+	fs := flag.NewFlagSet(op, flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Path to the monitored project")
+	supervisor := fs.Bool("supervisor", false, "Apply to every project of the supervisor")
+	var timeout *time.Duration
+	if op == "flush" {
+		timeout = fs.Duration("timeout", flushWaitTimeout, "How long to wait for the outbox to drain")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sock, project, err := controlTarget(*projectPath, *supervisor)
+	if err != nil {
+		return err
+	}
+	req := ControlRequest{Op: op, Project: project}
+	if timeout != nil {
+		req.Timeout = timeout.String()
+	}
+
+	if err := callAgent(sock, req, nil); err != nil {
+		return err
+	}
+
+	switch op {
+	case "snapshot":
+		fmt.Println("Snapshot taken")
+	case "pause":
+		fmt.Println("Monitoring paused")
+	case "resume":
+		fmt.Println("Monitoring resumed")
+	case "reload":
+		fmt.Println("Config reloaded")
+	case "flush":
+		fmt.Println("Outbox flushed")
+	}
+	return nil
+}
+
+// EventsCommand handles `daemon events`, printing the agent's recent events
+func EventsCommand(args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Path to the monitored project")
+	supervisor := fs.Bool("supervisor", false, "Show events of every project of the supervisor")
+	n := fs.Int("n", defaultTailEvents, "How many recent events to show")
+	follow := fs.Bool("f", false, "Keep printing new events")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sock, project, err := controlTarget(*projectPath, *supervisor)
+	if err != nil {
+		return err
+	}
+
+	return callAgent(sock, ControlRequest{Op: "tail", Project: project, N: *n, Follow: *follow}, func(raw json.RawMessage) error {
+		var ev Event
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return fmt.Errorf("bad event: %w", err)
+		}
+		line := ev.Time.Format(time.RFC3339) + " " + ev.Kind
+		if *supervisor && ev.Project != "" {
+			line += " [" + ev.Project + "]"
+		}
+		if ev.Message != "" {
+			line += ": " + ev.Message
+		}
+		fmt.Println(line)
+		return nil
+	})
+}
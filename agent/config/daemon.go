@@ -0,0 +1,304 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	outbox "github.com/internal-hackathon-7/int-hack-7/agent/lib/outbox"
+)
+
+// defaultStopTimeout is how long `daemon stop` waits after SIGTERM before killing the agent
+const defaultStopTimeout = 10 * time.Second
+
+// defaultInterval is the tick period when neither the config nor the room sets one
+const defaultInterval = 10 * time.Second
+
+// StartBackground launches a detached `daemon run` for the project and returns its PID.
+// The agent reads everything else from the project's config.
+func StartBackground(projectPath string) (int, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	cmd := exec.Command(exePath,
+		"run",
+		"-path", projectPath,
+	)
+
+	// detach from terminal (run in background)
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	cmd.Stdin = nil
+
+	SetDetachAttr(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start background command: %w", err)
+	}
+	return cmd.Process.Pid, nil
+}
+
+// StopCommand handles `daemon stop`: SIGTERM, wait, then SIGKILL
+func StopCommand(args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Path to the monitored project")
+	timeout := fs.Duration("timeout", defaultStopTimeout, "How long to wait for a clean shutdown before killing")
+	supervisor := fs.Bool("supervisor", false, "Stop the supervisor and every project it monitors instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *supervisor {
+		pidPath, err := supervisorPIDPath()
+		if err != nil {
+			return err
+		}
+		return stopProcess(pidPath, "Supervisor", *timeout)
+	}
+	return stopAgent(*projectPath, *timeout)
+}
+
+// stopAgent stops the project's own agent; supervised projects are left to `daemon remove`
+func stopAgent(projectPath string, timeout time.Duration) error {
+	if pid, running, err := agentRunning(projectPath); err == nil && running && supervisedBy(projectPath, pid) {
+		return fmt.Errorf("%s is monitored by the supervisor (PID %d), use `%s remove %s`", projectPath, pid, DisplayName, projectPath)
+	}
+	return stopProcess(pidFilePath(projectPath), "Agent", timeout)
+}
+
+// stopProcess stops whoever holds the PID file at pidPath; name labels the output
+func stopProcess(pidPath, name string, timeout time.Duration) error {
+	pid, running, err := pidFileHeld(pidPath)
+	if err != nil {
+		return err
+	}
+	if !running {
+		if pid != 0 {
+			os.Remove(pidPath)
+			fmt.Printf("%s not running (removed stale PID %d)\n", name, pid)
+		} else {
+			fmt.Println(name, "not running")
+		}
+		return nil
+	}
+
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("failed to find process %d: %w", pid, err)
+	}
+
+	fmt.Printf("Stopping %s (PID %d)...\n", strings.ToLower(name), pid)
+	if err := terminateProcess(p); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to signal %s: %w", strings.ToLower(name), err)
+	}
+	if waitForExit(pidPath, timeout) {
+		// a process ended by the signal's default action leaves its PID file behind
+		os.Remove(pidPath)
+		fmt.Println(name, "stopped")
+		return nil
+	}
+
+	fmt.Printf("%s did not exit within %s, killing it\n", name, timeout)
+	if err := p.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to kill %s: %w", strings.ToLower(name), err)
+	}
+	if !waitForExit(pidPath, 5*time.Second) {
+		return fmt.Errorf("%s (PID %d) is still running", strings.ToLower(name), pid)
+	}
+
+	// a killed process can't clean up after itself
+	os.Remove(pidPath)
+	fmt.Println(name, "killed")
+	return nil
+}
+
+// waitForExit polls the PID file lock until its holder lets go of it
+func waitForExit(pidPath string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if _, running, err := pidFileHeld(pidPath); err == nil && !running {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// StatusCommand handles `daemon status`. A running agent is asked over its
+// control socket; otherwise the files it left behind are read.
+func StatusCommand(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Path to the monitored project")
+	supervisor := fs.Bool("supervisor", false, "Show the supervisor and every project it monitors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *supervisor {
+		return supervisorStatus()
+	}
+
+	pid, running, err := agentRunning(*projectPath)
+	if err != nil {
+		return err
+	}
+
+	if running {
+		sock, project, err := controlTarget(*projectPath, false)
+		var res StatusResult
+		if err == nil {
+			err = callAgent(sock, ControlRequest{Op: "status", Project: project}, decodeInto(&res))
+		}
+		if err == nil && len(res.Projects) == 1 {
+			ps := res.Projects[0]
+			printStatus(ps.agentStatus, pid, true)
+			fmt.Printf("Outbox:         %d pending\n", ps.OutboxPending)
+			return nil
+		}
+		if err != nil {
+			fmt.Printf("Control API:    unreachable: %v\n", err)
+		}
+	}
+
+	st, err := readStatusFile(*projectPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Status:         unreadable: %v\n", err)
+	}
+	printStatus(st, pid, running)
+
+	// supervised projects spool into the supervisor's shared outbox
+	depth, err := outbox.PendingDepth(*projectPath)
+	if st.OutboxDir != "" {
+		depth, err = outbox.PendingDepthDir(st.OutboxDir)
+	}
+	if err != nil {
+		fmt.Printf("Outbox:         unreadable: %v\n", err)
+	} else {
+		fmt.Printf("Outbox:         %d pending\n", depth)
+	}
+
+	return nil
+}
+
+// supervisorStatus prints the supervisor and each project it monitors
+func supervisorStatus() error {
+	pidPath, err := supervisorPIDPath()
+	if err != nil {
+		return err
+	}
+	pid, running, err := pidFileHeld(pidPath)
+	if err != nil {
+		return err
+	}
+	if !running {
+		fmt.Println("Supervisor:     not running")
+		return nil
+	}
+
+	sock, _, err := controlTarget("", true)
+	if err != nil {
+		return err
+	}
+	var res StatusResult
+	if err := callAgent(sock, ControlRequest{Op: "status"}, decodeInto(&res)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Supervisor:     running (PID %d), %d projects\n", pid, len(res.Projects))
+	for i, ps := range res.Projects {
+		if i == 0 {
+			fmt.Printf("Outbox:         %d pending\n", ps.OutboxPending)
+		}
+		fmt.Println()
+		fmt.Printf("Project:        %s\n", ps.Project)
+		printStatus(ps.agentStatus, pid, true)
+	}
+	return nil
+}
+
+// printStatus prints what an agent reported about one project
+func printStatus(st agentStatus, pid int, running bool) {
+	state := "running"
+	if st.Supervised {
+		state = "running under the supervisor"
+	}
+	if st.Paused {
+		state += ", PAUSED"
+	}
+
+	switch {
+	case running:
+		fmt.Printf("Agent:          %s (PID %d)\n", state, pid)
+	case pid != 0:
+		fmt.Printf("Agent:          not running (stale PID %d)\n", pid)
+	default:
+		fmt.Println("Agent:          not running")
+	}
+
+	if st.StartedAt.IsZero() {
+		return
+	}
+	if running {
+		fmt.Printf("Started:        %s\n", formatWhen(st.StartedAt))
+	}
+	switch {
+	case st.Interval != st.ConfiguredInterval:
+		fmt.Printf("Interval:       %s (configured %s, room bounds %s)\n", st.Interval, st.ConfiguredInterval, formatBounds(st.IntervalMin, st.IntervalMax))
+	case st.IntervalMin > 0 || st.IntervalMax > 0:
+		fmt.Printf("Interval:       %s (room bounds %s)\n", st.Interval, formatBounds(st.IntervalMin, st.IntervalMax))
+	default:
+		fmt.Printf("Interval:       %s\n", st.Interval)
+	}
+	fmt.Printf("Last snapshot:  %s\n", formatWhen(st.LastSnapshot))
+
+	switch {
+	case st.LastUpload.IsZero():
+		fmt.Println("Last upload:    never")
+	case st.LastUploadError != "":
+		fmt.Printf("Last upload:    FAILED %s (%s): %s\n", formatWhen(st.LastUpload), st.LastUploadKind, st.LastUploadError)
+	default:
+		fmt.Printf("Last upload:    ok %s (%s)\n", formatWhen(st.LastUpload), st.LastUploadKind)
+	}
+}
+
+// RestartCommand handles `daemon restart`. The config is checked first so a
+// broken edit doesn't take down a working agent.
+func RestartCommand(args []string) error {
+	fs := flag.NewFlagSet("restart", flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Path to the monitored project")
+	timeout := fs.Duration("timeout", defaultStopTimeout, "How long to wait for a clean shutdown before killing")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := Load(Overrides{Path: *projectPath})
+	if err != nil {
+		return fmt.Errorf("invalid config, agent left running:\n%w", err)
+	}
+
+	if err := stopAgent(cfg.ProjectPath, *timeout); err != nil {
+		return err
+	}
+
+	pid, err := StartBackground(cfg.ProjectPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started background process with PID %d\n", pid)
+	return nil
+}
+
+func formatWhen(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), time.Since(t).Round(time.Second))
+}
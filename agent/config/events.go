@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventLogSize is how many recent events `daemon events` can replay
+const eventLogSize = 200
+
+// Event is something a running agent did, as streamed by the control API's tail
+type Event struct {
+	Time    time.Time `json:"time"`
+	Project string    `json:"project,omitempty"`
+	// Kind is one of tick, tick_failed, upload, upload_failed, reload,
+	// reload_rejected, paused or resumed
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+// eventLog keeps the last eventLogSize events in memory and fans new ones
+// out to followers. Followers that fall behind miss events rather than
+// stalling the agent.
+type eventLog struct {
+	mu        sync.Mutex
+	events    []Event
+	followers map[chan Event]struct{}
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{followers: map[chan Event]struct{}{}}
+}
+
+func (l *eventLog) add(project, kind, format string, args ...any) {
+	ev := Event{Time: time.Now(), Project: project, Kind: kind, Message: fmt.Sprintf(format, args...)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, ev)
+	if len(l.events) > eventLogSize {
+		l.events = l.events[len(l.events)-eventLogSize:]
+	}
+	for ch := range l.followers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// recent returns up to n of the latest events for project, or for every
+// project when it is empty, oldest first
+func (l *eventLog) recent(project string, n int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var out []Event
+	for i := len(l.events) - 1; i >= 0 && len(out) < n; i-- {
+		if project == "" || l.events[i].Project == project || l.events[i].Project == "" {
+			out = append(out, l.events[i])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// follow returns a channel of new events; stop must be called when done
+func (l *eventLog) follow() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+
+	l.mu.Lock()
+	l.followers[ch] = struct{}{}
+	l.mu.Unlock()
+
+	return ch, func() {
+		l.mu.Lock()
+		delete(l.followers, ch)
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	lib "github.com/internal-hackathon-7/int-hack-7/agent/lib/cmd"
+)
+
+// ShellHookCommand handles `daemon shell-hook bash|zsh|fish`, printing the snippet to eval
+func ShellHookCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s shell-hook <bash|zsh|fish>", DisplayName)
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	snippet, err := lib.ShellHook(args[0], exePath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(snippet)
+	return nil
+}
+
+// HookReportCommand handles `daemon hook-report`, called by the shell hooks after every command
+func HookReportCommand(args []string) error {
+	fs := flag.NewFlagSet("hook-report", flag.ContinueOnError)
+	exit := fs.Int("exit", 0, "Exit status of the command")
+	start := fs.String("start", "", "Start time, unix seconds")
+	end := fs.String("end", "", "End time, unix seconds")
+	durationMs := fs.Int64("duration-ms", -1, "Duration in milliseconds, instead of -start")
+	cwd := fs.String("cwd", "", "Directory the command ran in")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	command := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if command == "" {
+		return nil
+	}
+
+	endTime := parseEpoch(*end)
+	if endTime == 0 {
+		endTime = float64(time.Now().UnixNano()) / 1e9
+	}
+	startTime := parseEpoch(*start)
+	if *durationMs >= 0 {
+		startTime = endTime - float64(*durationMs)/1000
+	}
+	if startTime == 0 {
+		startTime = endTime
+	}
+
+	return lib.ReportCommand(lib.HookEvent{
+		Command: command,
+		Cwd:     *cwd,
+		Start:   startTime,
+		End:     endTime,
+		Exit:    *exit,
+	})
+}
+
+// parseEpoch accepts $EPOCHREALTIME, which uses the locale's decimal separator
+func parseEpoch(s string) float64 {
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
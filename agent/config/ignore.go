@@ -0,0 +1,90 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// IgnoreCommand handles `daemon ignore add|list|remove`, editing daemon_ignore in .daemon/config.yaml
+func IgnoreCommand(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: %s ignore <add|list|remove> [-path dir] [pattern...]", DisplayName)
+	}
+
+	sub := args[0]
+	fs := flag.NewFlagSet("ignore "+sub, flag.ExitOnError)
+	projectPath := fs.String("path", ".", "Path to the monitored project")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	patterns := fs.Args()
+
+	cfg, err := LoadProjectConfig(*projectPath)
+	if err != nil {
+		return fmt.Errorf("run `%s init` first: %w", DisplayName, err)
+	}
+
+	switch sub {
+	case "list":
+		if len(cfg.DaemonIgnore) == 0 {
+			fmt.Println("No daemon_ignore patterns.")
+		}
+		for _, p := range cfg.DaemonIgnore {
+			fmt.Println(p)
+		}
+		return nil
+
+	case "add":
+		if len(patterns) == 0 {
+			return fmt.Errorf("usage: %s ignore add <pattern...>", DisplayName)
+		}
+		for _, p := range patterns {
+			if slices.Contains(cfg.DaemonIgnore, p) {
+				fmt.Printf("%s already ignored\n", p)
+				continue
+			}
+			if strings.TrimSpace(p) == "" {
+				return fmt.Errorf("invalid pattern %q", p)
+			}
+			cfg.DaemonIgnore = append(cfg.DaemonIgnore, p)
+			fmt.Printf("Ignoring %s\n", p)
+		}
+
+	case "remove":
+		if len(patterns) == 0 {
+			return fmt.Errorf("usage: %s ignore remove <pattern...>", DisplayName)
+		}
+		for _, p := range patterns {
+			i := slices.Index(cfg.DaemonIgnore, p)
+			if i < 0 {
+				fmt.Printf("%s is not in daemon_ignore\n", p)
+				continue
+			}
+			cfg.DaemonIgnore = slices.Delete(cfg.DaemonIgnore, i, i+1)
+			fmt.Printf("No longer ignoring %s\n", p)
+		}
+
+	default:
+		return fmt.Errorf("unknown ignore command: %s", sub)
+	}
+
+	if _, err := SaveProjectConfig(*projectPath, cfg); err != nil {
+		return err
+	}
+
+	if _, running, err := agentRunning(*projectPath); err != nil || !running {
+		return nil
+	}
+	sock, project, err := controlTarget(*projectPath, false)
+	if err == nil {
+		err = callAgent(sock, ControlRequest{Op: "reload", Project: project}, nil)
+	}
+	if err != nil {
+		fmt.Printf("Could not reach the agent (%v), it picks up the change at its next tick.\n", err)
+		return nil
+	}
+	fmt.Println("Agent reloaded.")
+	return nil
+}
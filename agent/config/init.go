@@ -2,6 +2,8 @@ package config
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,7 +14,7 @@ import (
 	"time"
 
 	"github.com/internal-hackathon-7/int-hack-7/agent/constants"
-	"github.com/internal-hackathon-7/int-hack-7/agent/controller"
+	master "github.com/internal-hackathon-7/int-hack-7/agent/lib/master"
 	"github.com/internal-hackathon-7/int-hack-7/agent/types"
 	"gopkg.in/yaml.v3"
 )
@@ -21,85 +23,252 @@ import (
 const DisplayName = "daemon"
 const DefaultProjectPath = "/Users/aditya/99-trash/dummy"
 
-// startService simulates a background daemon
-func StartService(projectPath string, interval int) {
-	ticker := time.NewTicker(time.Duration(interval * int(time.Second)))
-	defer ticker.Stop()
+// SaveProjectConfig writes cfg to .daemon/config.yaml and returns the file path
+func SaveProjectConfig(projectPath string, cfg types.ProjectConfig) (string, error) {
+	configDir := filepath.Join(projectPath, ".daemon")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create .daemon directory: %w", err)
+	}
+	configFile := projectConfigPath(projectPath)
 
-	for range ticker.C {
+	cfg.SchemaVersion = SchemaVersion
+	data, err := yaml.Marshal(&cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config.yaml: %w", err)
+	}
 
-		diffBlob, err := controller.ComputeDiff(projectPath)
-		if err != nil {
-			log.Panic("DIFF error")
-		}
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write config.yaml: %w", err)
+	}
 
-		cmdDiffBlob, err := controller.ComputeCmdDiff(projectPath)
-		if err != nil {
-			log.Panic("CMD DIFF error")
-		}
+	return configFile, nil
+}
+
+// LoadProjectConfig reads only the project's .daemon/config.yaml, for
+// commands that edit it. The agent itself uses Load.
+func LoadProjectConfig(projectPath string) (types.ProjectConfig, error) {
+	var cfg types.ProjectConfig
+
+	data, err := readConfigFile(projectConfigPath(projectPath))
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config.yaml: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config.yaml: %w", err)
+	}
+
+	if cfg.ProjectPath == "" {
+		cfg.ProjectPath = projectPath
+	}
 
-		log.Println("")
-		log.Printf("%#v\n", diffBlob.Changes)
-		log.Println("")
-		log.Printf("%#v\n", cmdDiffBlob)
-		log.Println("")
+	return cfg, nil
+}
 
-		//idhar then send diff json...to master ;)
-		//also send the cmd diff to master
-		log.Println("")
-		log.Println("one iteration successfull")
-		log.Println("")
+// ConnectRoom joins the member to the room on the master.
+// It returns the room's interval bounds and the member's googleId.
+func ConnectRoom(roomID string, emailID string) (interval master.RoomInterval, memberID string, err error) {
+	memberID, interval, err = master.JoinRoom(roomID, emailID)
+	if err != nil {
+		return interval, "", err
 	}
+	return interval, memberID, nil
 }
 
-func ConnectRoom(roomID string, emailID string) (interval int, err error) {
-	ans := 5
-	//api call to connect to room
-	//also send email to identify user.
-	//asks master for the interval
-	//returns interval (seconds)
-	return ans, nil
+// initOptions are the `daemon init` settings, from flags or DAEMON_* variables
+type initOptions struct {
+	email     string
+	room      string
+	path      string
+	interval  time.Duration
+	ignore    stringList
+	masterURL string
+	yes       bool
 }
 
-// InitCommand handles `daemon init`
-func InitCommand() (string, int, string, error) {
-	fmt.Println("Welcome to Daemon setup!")
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func parseInitFlags(args []string) (initOptions, error) {
+	var opts initOptions
+
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.StringVar(&opts.email, "email", os.Getenv("DAEMON_EMAIL"), "Email of your account on the website (env DAEMON_EMAIL)")
+	fs.StringVar(&opts.room, "room", os.Getenv("DAEMON_ROOM"), "Room ID to join (env DAEMON_ROOM)")
+	fs.StringVar(&opts.path, "path", os.Getenv("DAEMON_PATH"), "Project path to monitor (env DAEMON_PATH)")
+	fs.Func("interval", "Polling interval like 30s or 5m, default the room's (env DAEMON_INTERVAL)", func(v string) error {
+		d, err := types.ParseInterval(v)
+		opts.interval = d
+		return err
+	})
+	fs.Var(&opts.ignore, "ignore", "daemon_ignore pattern, repeatable (env DAEMON_IGNORE, comma separated)")
+	fs.StringVar(&opts.masterURL, "master-url", os.Getenv("DAEMON_MASTER_URL"), "Master server URL (env DAEMON_MASTER_URL, else MasterURL)")
+	fs.BoolVar(&opts.yes, "yes", false, "Never prompt; fail if a required setting is missing")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+
+	// flag defaults can't carry env values that need parsing
+	if !isFlagSet(fs, "interval") {
+		if v := os.Getenv("DAEMON_INTERVAL"); v != "" {
+			d, err := types.ParseInterval(v)
+			if err != nil {
+				return opts, fmt.Errorf("invalid DAEMON_INTERVAL: %w", err)
+			}
+			opts.interval = d
+		}
+	}
+	if !isFlagSet(fs, "ignore") {
+		for _, p := range strings.Split(os.Getenv("DAEMON_IGNORE"), ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				opts.ignore = append(opts.ignore, p)
+			}
+		}
+	}
+
+	if opts.interval < 0 {
+		return opts, fmt.Errorf("--interval must not be negative")
+	}
+	return opts, nil
+}
 
-	emailID := prompt("Signup to the Website and then Enter your emailID", "")
-	roomID := prompt("Enter your room ID", "")
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
 
-	interval, err := ConnectRoom(roomID, emailID)
+// InitCommand handles `daemon init`. Settings come from flags or DAEMON_*
+// variables; whatever is missing is prompted for on a terminal, and is an
+// error with --yes or without one.
+func InitCommand(args []string) (string, error) {
+	opts, err := parseInitFlags(args)
 	if err != nil {
-		log.Fatalf("Room ID [%v] NOT FOUND", roomID)
+		return "", err
+	}
+
+	interactive := !opts.yes && isTerminal(os.Stdin)
+	if !interactive {
+		var missing []string
+		if opts.email == "" {
+			missing = append(missing, "--email (DAEMON_EMAIL)")
+		}
+		if opts.room == "" {
+			missing = append(missing, "--room (DAEMON_ROOM)")
+		}
+		if opts.path == "" {
+			missing = append(missing, "--path (DAEMON_PATH)")
+		}
+		if len(missing) > 0 {
+			return "", fmt.Errorf("missing required settings: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	if opts.masterURL != "" {
+		constants.MasterURL = opts.masterURL
+	}
+	if constants.MasterURL == "" {
+		return "", fmt.Errorf("no master URL: pass --master-url or set DAEMON_MASTER_URL")
+	}
+
+	if err := PingMaster(); err != nil {
+		return "", fmt.Errorf("connection NOT established, service down: %w", err)
+	}
+
+	if interactive {
+		fmt.Println("Welcome to Daemon setup!")
 	}
 
-	projectPath := prompt("Enter the project path to monitor", DefaultProjectPath)
+	emailID := opts.email
+	if emailID == "" {
+		emailID = prompt("Signup to the Website and then Enter your emailID", "")
+	}
+	roomID := opts.room
+	if roomID == "" {
+		roomID = prompt("Enter your room ID", "")
+	}
+
+	var room master.RoomInterval
+	var memberID string
+	for {
+		var err error
+		room, memberID, err = ConnectRoom(roomID, emailID)
+		if err == nil {
+			break
+		}
+
+		switch {
+		case interactive && errors.Is(err, master.ErrMemberNotFound):
+			fmt.Printf("No account found for %s, signup on the website first.\n", emailID)
+			emailID = prompt("Enter your emailID", "")
+		case interactive && errors.Is(err, master.ErrRoomNotFound):
+			fmt.Printf("Room ID [%v] NOT FOUND\n", roomID)
+			roomID = prompt("Enter your room ID", "")
+		default:
+			return "", err
+		}
+	}
+
+	interval := defaultInterval
+	switch {
+	case opts.interval > 0:
+		interval = opts.interval
+	case room.Default > 0:
+		interval = room.Default
+	}
+
+	projectPath := opts.path
+	if projectPath == "" {
+		projectPath = prompt("Enter the project path to monitor", DefaultProjectPath)
+	}
+	// the agent runs detached, possibly from another directory
+	if abs, err := filepath.Abs(projectPath); err == nil {
+		projectPath = abs
+	}
 
 	config := types.ProjectConfig{
-		ProjectPath: projectPath,
-		RoomID:      roomID,
-		Interval:    interval,
-		EmailID:     emailID,
+		MasterURL:    constants.MasterURL,
+		ProjectPath:  projectPath,
+		RoomID:       roomID,
+		Interval:     interval,
+		IntervalMin:  room.Min,
+		IntervalMax:  room.Max,
+		EmailID:      emailID,
+		MemberID:     memberID,
+		DaemonIgnore: opts.ignore,
 	}
 
-	configDir := filepath.Join(projectPath, ".daemon")
-	os.MkdirAll(configDir, 0755)
-	configFile := filepath.Join(configDir, "config.yaml")
+	if err := os.MkdirAll(projectPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create project directory: %w", err)
+	}
+	if err := Validate(config); err != nil {
+		return "", fmt.Errorf("invalid settings:\n%w", err)
+	}
 
-	data, err := yaml.Marshal(&config)
-	if err != nil {
-		return "", 0, "", err
+	if effective := EffectiveInterval(config); effective != interval {
+		fmt.Printf("Room %s bounds the interval to %s, the agent will tick every %s\n", roomID, formatBounds(room.Min, room.Max), effective)
 	}
 
-	err = os.WriteFile(configFile, data, 0644)
+	configFile, err := SaveProjectConfig(projectPath, config)
 	if err != nil {
-		return "", 0, "", err
+		return "", err
 	}
 
 	fmt.Println("Config saved at", configFile)
 	fmt.Println("Proceeding to start service")
 
-	return projectPath, interval, emailID, nil
+	return projectPath, nil
 }
 
 func PingMaster() error {
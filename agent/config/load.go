@@ -0,0 +1,308 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the layout of config.yaml written by this agent. Older
+// files are migrated when loaded.
+const SchemaVersion = 3
+
+// Overrides are settings given as `daemon run` flags; empty values are unset
+type Overrides struct {
+	Path      string
+	Interval  time.Duration
+	Email     string
+	Room      string
+	MasterURL string
+}
+
+// FieldError is a config value that failed validation
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Load builds the agent's config. Later sources win: the user-global
+// ~/.config/daemon/config.yaml, the project's .daemon/config.yaml, DAEMON_*
+// environment variables, then flags. The result is validated.
+func Load(flags Overrides) (types.ProjectConfig, error) {
+	cfg := types.ProjectConfig{Interval: defaultInterval}
+
+	projectPath := firstNonEmpty(flags.Path, os.Getenv("DAEMON_PATH"), ".")
+	projectPath, err := filepath.Abs(projectPath)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to resolve project path: %w", err)
+	}
+
+	if globalFile, err := GlobalConfigPath(); err == nil {
+		if err := loadLayer(globalFile, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if err := loadLayer(projectConfigPath(projectPath), &cfg); err != nil {
+		return cfg, err
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	if flags.Interval != 0 {
+		cfg.Interval = flags.Interval
+	}
+	if flags.Email != "" {
+		cfg.EmailID = flags.Email
+	}
+	if flags.Room != "" {
+		cfg.RoomID = flags.Room
+	}
+	if flags.MasterURL != "" {
+		cfg.MasterURL = flags.MasterURL
+	}
+
+	// the directory the file was found in is the project, whatever it says
+	cfg.ProjectPath = projectPath
+	cfg.SchemaVersion = SchemaVersion
+
+	return cfg, Validate(cfg)
+}
+
+// GlobalConfigPath is the per-user config shared by every project,
+// $XDG_CONFIG_HOME/daemon/config.yaml defaulting to ~/.config
+func GlobalConfigPath() (string, error) {
+	dir, err := userDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// userDir holds the per-user config and the supervisor's files
+func userDir() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, DisplayName), nil
+}
+
+func projectConfigPath(projectPath string) string {
+	return filepath.Join(projectPath, ".daemon", "config.yaml")
+}
+
+// Validate reports every invalid field, not just the first
+func Validate(cfg types.ProjectConfig) error {
+	var errs []error
+	invalid := func(field, format string, args ...any) {
+		errs = append(errs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.MasterURL == "" {
+		invalid("master_url", "missing (set it in config.yaml, DAEMON_MASTER_URL or --master-url)")
+	} else if u, err := url.Parse(cfg.MasterURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("master_url", "%q is not an http(s) URL", cfg.MasterURL)
+	}
+	if cfg.RoomID == "" {
+		invalid("room_id", "missing")
+	}
+	if cfg.EmailID == "" {
+		invalid("email_id", "missing")
+	} else if !strings.Contains(cfg.EmailID, "@") {
+		invalid("email_id", "%q is not an email address", cfg.EmailID)
+	}
+	if cfg.Interval < time.Second {
+		invalid("interval", "must be at least 1s, got %s", cfg.Interval)
+	}
+	if cfg.IntervalMin < 0 || cfg.IntervalMax < 0 || (cfg.IntervalMax > 0 && cfg.IntervalMin > cfg.IntervalMax) {
+		invalid("interval_min", "room bounds %s..%s are not a range", cfg.IntervalMin, cfg.IntervalMax)
+	}
+	if cfg.RenameThreshold < 0 || cfg.RenameThreshold > 100 {
+		invalid("rename_threshold", "must be between 0 and 100, got %d", cfg.RenameThreshold)
+	}
+	if info, err := os.Stat(cfg.ProjectPath); err != nil || !info.IsDir() {
+		invalid("project_path", "%q is not a directory", cfg.ProjectPath)
+	}
+
+	return errors.Join(errs...)
+}
+
+// applyEnv overlays DAEMON_* variables. MasterURL is the name older .env files use.
+func applyEnv(cfg *types.ProjectConfig) error {
+	if v := firstNonEmpty(os.Getenv("DAEMON_MASTER_URL"), os.Getenv("MasterURL")); v != "" {
+		cfg.MasterURL = v
+	}
+	if v := os.Getenv("DAEMON_EMAIL"); v != "" {
+		cfg.EmailID = v
+	}
+	if v := os.Getenv("DAEMON_ROOM"); v != "" {
+		cfg.RoomID = v
+	}
+	if v := os.Getenv("DAEMON_SHELL"); v != "" {
+		cfg.DefaultShell = v
+	}
+	if v := os.Getenv("DAEMON_INTERVAL"); v != "" {
+		d, err := types.ParseInterval(v)
+		if err != nil {
+			return &FieldError{Field: "DAEMON_INTERVAL", Message: err.Error()}
+		}
+		cfg.Interval = d
+	}
+	if v := os.Getenv("DAEMON_IGNORE"); v != "" {
+		cfg.DaemonIgnore = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				cfg.DaemonIgnore = append(cfg.DaemonIgnore, p)
+			}
+		}
+	}
+	return nil
+}
+
+// EffectiveInterval is the tick period the agent actually uses: the
+// configured one, clamped to the room's bounds
+func EffectiveInterval(cfg types.ProjectConfig) time.Duration {
+	d := cfg.Interval
+	if cfg.IntervalMin > 0 && d < cfg.IntervalMin {
+		d = cfg.IntervalMin
+	}
+	if cfg.IntervalMax > 0 && d > cfg.IntervalMax {
+		d = cfg.IntervalMax
+	}
+	return d
+}
+
+// formatBounds describes a room's interval bounds for humans
+func formatBounds(lo, hi time.Duration) string {
+	switch {
+	case lo > 0 && hi > 0:
+		return fmt.Sprintf("%s-%s", lo, hi)
+	case lo > 0:
+		return fmt.Sprintf("min %s", lo)
+	case hi > 0:
+		return fmt.Sprintf("max %s", hi)
+	}
+	return "none"
+}
+
+// loadLayer overlays the keys present in file onto cfg, migrating the file
+// first if it was written by an older agent. A missing file is no error.
+func loadLayer(file string, cfg *types.ProjectConfig) error {
+	data, err := readConfigFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return nil
+}
+
+// readConfigFile returns the file's YAML at the current schema version,
+// rewriting the file when a migration changed it
+func readConfigFile(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	if raw == nil {
+		raw = map[string]any{}
+	}
+
+	from, migrated, err := migrateConfig(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate %s: %w", file, err)
+	}
+	if !migrated {
+		return data, nil
+	}
+
+	out, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", file, err)
+	}
+	if err := os.WriteFile(file+".bak", data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to back up %s: %w", file, err)
+	}
+	if err := os.WriteFile(file, out, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	log.Printf("Migrated %s from schema %d to %d (old file kept as %s.bak)\n", file, from, SchemaVersion, filepath.Base(file))
+
+	return out, nil
+}
+
+// migrations[v] upgrades a schema v file to v+1
+var migrations = map[int]func(raw map[string]any){
+	// v1 had no schema_version and an auth_token nothing ever read or set
+	1: func(raw map[string]any) {
+		delete(raw, "auth_token")
+	},
+	// v2 stored interval_minutes, which every agent so far ticked in seconds;
+	// keep the period it actually ran at
+	2: func(raw map[string]any) {
+		if n, ok := raw["interval_minutes"].(int); ok && n > 0 {
+			raw["interval"] = (time.Duration(n) * time.Second).String()
+		}
+		delete(raw, "interval_minutes")
+	},
+}
+
+// migrateConfig upgrades raw in place and reports the version it started from
+func migrateConfig(raw map[string]any) (int, bool, error) {
+	version := 1
+	if v, ok := raw["schema_version"]; ok {
+		n, ok := v.(int)
+		if !ok {
+			return 0, false, fmt.Errorf("schema_version %v is not a number", v)
+		}
+		version = n
+	}
+	if version > SchemaVersion {
+		return version, false, fmt.Errorf("schema_version %d is newer than this agent understands (%d)", version, SchemaVersion)
+	}
+
+	from := version
+	for ; version < SchemaVersion; version++ {
+		migrations[version](raw)
+	}
+	raw["schema_version"] = SchemaVersion
+
+	return from, from != SchemaVersion, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
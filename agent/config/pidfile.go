@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrAgentRunning is returned by LockPIDFile while another agent holds the lock
+var ErrAgentRunning = errors.New("an agent is already running for this project")
+
+var errLocked = errors.New("file is locked")
+
+// PIDFile is .daemon/agent.pid, held under an exclusive lock for as long as
+// the agent runs. The lock, not the PID, is what says an agent is alive.
+type PIDFile struct {
+	f    *os.File
+	path string
+}
+
+func pidFilePath(projectPath string) string {
+	return filepath.Join(projectPath, ".daemon", "agent.pid")
+}
+
+// LockPIDFile claims the project for this process and writes its PID
+func LockPIDFile(projectPath string) (*PIDFile, error) {
+	return lockPIDFile(pidFilePath(projectPath), ErrAgentRunning)
+}
+
+// lockPIDFile claims path, failing with held while another process has it
+func lockPIDFile(path string, held error) (*PIDFile, error) {
+	var f *os.File
+	for {
+		// no O_TRUNC: the PID of a running agent must survive a refused start
+		var err error
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open PID file: %w", err)
+		}
+
+		if err := tryLock(f); err != nil {
+			f.Close()
+			if errors.Is(err, errLocked) {
+				pid, _ := readPID(path)
+				return nil, fmt.Errorf("%w (pid %d)", held, pid)
+			}
+			return nil, fmt.Errorf("failed to lock PID file: %w", err)
+		}
+
+		current, err := isCurrentFile(f, path)
+		if err != nil {
+			unlock(f)
+			f.Close()
+			return nil, err
+		}
+		if current {
+			break
+		}
+		// the file was released and unlinked while we waited for its lock;
+		// another start may already hold the new one, so try that instead
+		unlock(f)
+		f.Close()
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to truncate PID file: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write PID file: %w", err)
+	}
+
+	return &PIDFile{f: f, path: path}, nil
+}
+
+// isCurrentFile reports whether f is still the file at path. A lock on a
+// PID file that has since been unlinked claims nothing.
+func isCurrentFile(f *os.File, path string) (bool, error) {
+	opened, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("failed to stat PID file: %w", err)
+	}
+	onDisk, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat PID file: %w", err)
+	}
+	return os.SameFile(opened, onDisk), nil
+}
+
+// Release removes the PID file and drops the lock
+func (p *PIDFile) Release() {
+	os.Remove(p.path)
+	unlock(p.f)
+	p.f.Close()
+}
+
+// agentRunning reports the PID in the PID file and whether an agent still
+// holds its lock. A PID with running=false is stale.
+func agentRunning(projectPath string) (int, bool, error) {
+	return pidFileHeld(pidFilePath(projectPath))
+}
+
+// pidFileHeld is agentRunning for any PID file
+func pidFileHeld(path string) (int, bool, error) {
+	pid, err := readPID(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return pid, false, fmt.Errorf("failed to open PID file: %w", err)
+	}
+	defer f.Close()
+
+	if err := tryLock(f); err != nil {
+		if errors.Is(err, errLocked) {
+			return pid, true, nil
+		}
+		return pid, false, fmt.Errorf("failed to check PID file lock: %w", err)
+	}
+	unlock(f)
+	return pid, false, nil
+}
+
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	text := strings.TrimSpace(string(data))
+	if text == "" {
+		return 0, nil
+	}
+	pid, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID file %s: %w", path, err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockPIDFileRefusesSecondAgent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+
+	first, err := lockPIDFile(path, ErrAgentRunning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockPIDFile(path, ErrAgentRunning); !errors.Is(err, ErrAgentRunning) {
+		t.Fatalf("second lock: %v, want ErrAgentRunning", err)
+	}
+
+	first.Release()
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("PID file left behind: %v", err)
+	}
+
+	again, err := lockPIDFile(path, ErrAgentRunning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Release()
+}
+
+// A start that opened the PID file just before Release unlinked it wins a
+// lock on the old inode; that must not count while another start holds the
+// new file
+func TestLockOnUnlinkedPIDFileIsStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.pid")
+
+	first, err := lockPIDFile(path, ErrAgentRunning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	late, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+
+	first.Release()
+	third, err := lockPIDFile(path, ErrAgentRunning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Release()
+
+	if err := tryLock(late); err != nil {
+		t.Fatalf("old inode should be lockable: %v", err)
+	}
+	defer unlock(late)
+	if current, err := isCurrentFile(late, path); err != nil || current {
+		t.Fatalf("isCurrentFile = %v, %v; want false", current, err)
+	}
+}
@@ -3,10 +3,37 @@
 package config
 
 import (
+	"errors"
+	"os"
 	"os/exec"
 	"syscall"
+
+	"golang.org/x/sys/windows"
 )
 
 func SetDetachAttr(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: 0x00000200}
 }
+
+// terminateProcess stops the agent; windows has no SIGTERM to send
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+// lockOffset puts the lock past the PID text, since windows locks also block reads
+const lockOffset = 1 << 30
+
+// tryLock takes an exclusive lock on f without blocking; errLocked means another process holds it
+func tryLock(f *os.File) error {
+	ol := &windows.Overlapped{Offset: lockOffset}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLocked
+	}
+	return err
+}
+
+func unlock(f *os.File) error {
+	ol := &windows.Overlapped{Offset: lockOffset}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+}
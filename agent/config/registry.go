@@ -0,0 +1,79 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Registry is the supervisor's list of projects, kept in
+// $XDG_CONFIG_HOME/daemon/projects.yaml and edited by `daemon add|remove`
+type Registry struct {
+	Projects []RegistryEntry `yaml:"projects"`
+}
+
+// RegistryEntry is one supervised project. RoomID, when set, overrides the
+// room in the project's own config.
+type RegistryEntry struct {
+	Path   string `yaml:"path"`
+	RoomID string `yaml:"room_id,omitempty"`
+}
+
+func registryPath() (string, error) {
+	dir, err := userDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "projects.yaml"), nil
+}
+
+// loadRegistry reads the registry; a missing file is an empty registry
+func loadRegistry() (Registry, error) {
+	var reg Registry
+
+	path, err := registryPath()
+	if err != nil {
+		return reg, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return reg, nil
+	}
+	if err != nil {
+		return reg, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &reg); err != nil {
+		return reg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return reg, nil
+}
+
+// saveRegistry replaces the registry file atomically, so a running
+// supervisor never reads half of it
+func saveRegistry(reg Registry) error {
+	path, err := registryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	data, err := yaml.Marshal(&reg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal projects.yaml: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write projects.yaml: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func (r *Registry) index(path string) int {
+	return slices.IndexFunc(r.Projects, func(e RegistryEntry) bool { return e.Path == path })
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	master "github.com/internal-hackathon-7/int-hack-7/agent/lib/master"
+	redact "github.com/internal-hackathon-7/int-hack-7/agent/lib/redact"
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// configWatcher notices edits to the config files the agent loads by
+// comparing their size and mtime between ticks
+type configWatcher struct {
+	files []string
+	seen  map[string]fileStamp
+}
+
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func newConfigWatcher(projectPath string) *configWatcher {
+	files := []string{projectConfigPath(projectPath)}
+	if globalFile, err := GlobalConfigPath(); err == nil {
+		files = append(files, globalFile)
+	}
+	return watchFiles(files...)
+}
+
+// watchFiles starts watching files, taking their current state as unchanged
+func watchFiles(files ...string) *configWatcher {
+	c := &configWatcher{files: files, seen: map[string]fileStamp{}}
+	c.changed()
+	return c
+}
+
+// changed reports whether any file differs from the last call
+func (c *configWatcher) changed() bool {
+	changed := false
+	for _, file := range c.files {
+		var stamp fileStamp
+		if info, err := os.Stat(file); err == nil {
+			stamp = fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+		}
+		if stamp != c.seen[file] {
+			changed = true
+		}
+		c.seen[file] = stamp
+	}
+	return changed
+}
+
+// reload re-reads the config and applies it. An invalid config is logged,
+// returned and the running one kept.
+func (s *service) reload(reason string) error {
+	next, err := Load(s.flags)
+	if err == nil {
+		err = s.apply(next)
+	}
+	if err != nil {
+		log.Printf("config reload (%s) REJECTED, keeping the running config: %v\n", reason, err)
+		s.events.add(s.project, "reload_rejected", "%s: %v", reason, err)
+		return err
+	}
+	log.Printf("config reloaded (%s)\n", reason)
+	s.events.add(s.project, "reload", "%s", reason)
+	return nil
+}
+
+// apply swaps in next: ticker period, ignore patterns, redaction rules and
+// upload target. Nothing changes unless every part can be applied.
+func (s *service) apply(next types.ProjectConfig) error {
+	redactor, err := redact.NewRedactor(next.ProjectPath, next.RedactPatterns)
+	if err != nil {
+		return fmt.Errorf("redact_patterns: %w", err)
+	}
+
+	if s.supervised && next.MasterURL != s.cfg.MasterURL {
+		return fmt.Errorf("master_url: supervised projects upload to %s, restart the supervisor to change it", s.cfg.MasterURL)
+	}
+
+	if s.watcher != nil && !slices.Equal(next.DaemonIgnore, s.cfg.DaemonIgnore) {
+		if err := s.watcher.SetDaemonIgnore(next.DaemonIgnore); err != nil {
+			return fmt.Errorf("daemon_ignore: %w", err)
+		}
+	}
+
+	if next.PollOnly != s.cfg.PollOnly {
+		log.Printf("poll_only change takes effect after a restart\n")
+		next.PollOnly = s.cfg.PollOnly
+	}
+
+	if next.Interval != s.cfg.Interval || next.IntervalMin != s.cfg.IntervalMin || next.IntervalMax != s.cfg.IntervalMax {
+		interval := EffectiveInterval(next)
+		s.ticker.Reset(interval)
+		s.status.intervalChanged(next)
+		log.Printf("interval now %s\n", interval)
+	}
+
+	if next.MasterURL != s.cfg.MasterURL {
+		master.SetMasterURL(next.MasterURL)
+		log.Printf("uploading to %s\n", next.MasterURL)
+	}
+
+	s.redactor = redactor
+	s.cfg = next
+	return nil
+}
@@ -0,0 +1,388 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/controller"
+	cmdlib "github.com/internal-hackathon-7/int-hack-7/agent/lib/cmd"
+	gitlib "github.com/internal-hackathon-7/int-hack-7/agent/lib/git"
+	master "github.com/internal-hackathon-7/int-hack-7/agent/lib/master"
+	outbox "github.com/internal-hackathon-7/int-hack-7/agent/lib/outbox"
+	redact "github.com/internal-hackathon-7/int-hack-7/agent/lib/redact"
+	watch "github.com/internal-hackathon-7/int-hack-7/agent/lib/watch"
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// safetyNetInterval is how often a full snapshot walk still runs while the watcher is active
+const safetyNetInterval = 5 * time.Minute
+
+const (
+	// maxConsecutiveFailures stops the agent instead of retrying a tick forever
+	maxConsecutiveFailures = 10
+
+	minTickBackoff = 2 * time.Second
+	maxTickBackoff = 5 * time.Minute
+
+	// flushTimeout bounds the final outbox delivery on shutdown
+	flushTimeout = 5 * time.Second
+)
+
+// StartService runs the agent until ctx is cancelled or ticks keep failing.
+// On shutdown the in-flight tick completes and queued uploads get one last
+// chance to go out. flags are kept to reload the config with the same
+// precedence on SIGHUP or when a config file changes.
+func StartService(ctx context.Context, cfg types.ProjectConfig, flags Overrides) error {
+	master.SetMasterURL(cfg.MasterURL)
+
+	ob, err := outbox.Open(cfg.ProjectPath)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+
+	events := newEventLog()
+	svc, err := newService(cfg, flags, ob, events)
+	if err != nil {
+		return err
+	}
+	defer svc.close()
+
+	// uploads stop only after the last tick has queued its blobs
+	stopUploads := startUploads(ob, func(rec outbox.Record) error {
+		err := controller.Deliver(rec)
+		svc.status.uploadDone(rec.Kind, err)
+		uploadEvent(events, cfg.ProjectPath, rec.Kind, err)
+		return err
+	})
+	defer stopUploads()
+
+	var ctl *controlServer
+	sockPath, err := controlSocketPath(cfg.ProjectPath)
+	if err == nil {
+		ctl, err = listenControl(sockPath, &controlServer{
+			ob:       ob,
+			events:   events,
+			services: func() []*service { return []*service{svc} },
+		})
+	}
+	if err != nil {
+		log.Printf("Warning: control API unavailable: %v\n", err)
+	} else {
+		defer ctl.Close()
+	}
+
+	return svc.run(ctx)
+}
+
+// startUploads drains ob in the background. The returned func stops it and
+// gives whatever is still queued one last chance to go out.
+func startUploads(ob *outbox.Outbox, deliver func(outbox.Record) error) func() {
+	uploadCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ob.Run(uploadCtx, deliver)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		if err := ob.Flush(flushCtx, deliver); err != nil {
+			log.Printf("outbox flush incomplete, the rest is sent on next start: %v\n", err)
+		}
+	}
+}
+
+// newService prepares one project for monitoring, spooling into ob and
+// reporting to events. The master URL must already be set.
+func newService(cfg types.ProjectConfig, flags Overrides, ob *outbox.Outbox, events *eventLog) (*service, error) {
+	projectPath := cfg.ProjectPath
+
+	if err := gitlib.MigrateStateFile(projectPath, cfg.EmailID); err != nil {
+		log.Printf("Warning: could not import state.txt snapshots: %v\n", err)
+	}
+
+	refreshRoomInterval(&cfg)
+	if interval := EffectiveInterval(cfg); interval != cfg.Interval {
+		log.Printf("interval %s is outside the room's bounds (%s), ticking every %s\n", cfg.Interval, formatBounds(cfg.IntervalMin, cfg.IntervalMax), interval)
+	}
+
+	redactor, err := redact.NewRedactor(projectPath, cfg.RedactPatterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load redaction rules: %w", err)
+	}
+
+	svc := &service{
+		project:  projectPath,
+		cfg:      cfg,
+		flags:    flags,
+		ob:       ob,
+		redactor: redactor,
+		status:   newStatusFile(cfg, ob.Dir()),
+		events:   events,
+		calls:    make(chan serviceCall),
+		done:     make(chan struct{}),
+	}
+
+	hooks, err := cmdlib.ListenHooks(projectPath)
+	if err != nil {
+		log.Printf("Warning: shell hooks unavailable, using history files only: %v\n", err)
+	} else {
+		svc.hooks = hooks
+	}
+
+	// snapshots follow filesystem events; the ticker keeps collecting commands
+	// and forces a snapshot now and then in case an event was missed
+	if !cfg.PollOnly {
+		watcher, err := watch.NewWatcher(projectPath, cfg.DaemonIgnore)
+		if err != nil {
+			log.Printf("Warning: file watching unavailable, polling instead: %v\n", err)
+		} else {
+			svc.watcher = watcher
+		}
+	}
+
+	return svc, nil
+}
+
+// close releases the hook socket and the filesystem watcher
+func (s *service) close() {
+	if s.hooks != nil {
+		s.hooks.Close()
+	}
+	if s.watcher != nil {
+		s.watcher.Close()
+	}
+}
+
+// run ticks until ctx is cancelled or ticks keep failing
+func (s *service) run(ctx context.Context) error {
+	defer close(s.done)
+
+	var changes <-chan struct{}
+	if s.watcher != nil {
+		changes = s.watcher.Changes()
+	}
+
+	ticker := time.NewTicker(EffectiveInterval(s.cfg))
+	defer ticker.Stop()
+	s.ticker = ticker
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	configFiles := newConfigWatcher(s.cfg.ProjectPath)
+
+	var lastSnapshot time.Time
+	var retry <-chan time.Time
+	failures := 0
+	paused := false
+	for {
+		// while a failed tick waits for its retry, or the agent is paused,
+		// other triggers are ignored
+		tickC, changesC := ticker.C, changes
+		if retry != nil || paused {
+			tickC, changesC = nil, nil
+		}
+
+		snapshot := true
+		var forced *serviceCall
+		select {
+		case <-ctx.Done():
+			log.Println("shutdown requested")
+			return nil
+		case <-hup:
+			s.reload("SIGHUP")
+			configFiles.changed()
+			continue
+		case call := <-s.calls:
+			switch call.op {
+			case "snapshot":
+				forced = &call
+			case "pause", "resume":
+				paused = call.op == "pause"
+				retry = nil
+				s.status.update(func(st *agentStatus) { st.Paused = paused })
+				s.events.add(s.project, call.op+"d", "")
+				log.Printf("monitoring %sd over the control API\n", call.op)
+				call.reply <- nil
+				continue
+			case "reload":
+				call.reply <- s.reload("control API")
+				configFiles.changed()
+				continue
+			default:
+				call.reply <- fmt.Errorf("unknown operation %q", call.op)
+				continue
+			}
+		case <-changesC:
+		case <-retry:
+		case <-tickC:
+			snapshot = changesC == nil || time.Since(lastSnapshot) >= safetyNetInterval
+		}
+
+		// edits apply between ticks, never halfway through one
+		if configFiles.changed() {
+			s.reload("config file change")
+		}
+
+		err := s.safeTick(snapshot)
+		if forced != nil {
+			forced.reply <- err
+		}
+		if err != nil {
+			failures++
+			s.events.add(s.project, "tick_failed", "%v", err)
+			if failures >= maxConsecutiveFailures {
+				return fmt.Errorf("giving up after %d consecutive failed ticks: %w", failures, err)
+			}
+			delay := min(minTickBackoff<<(failures-1), maxTickBackoff)
+			log.Printf("tick FAILED (%d in a row), retrying in %s: %v\n", failures, delay, err)
+			retry = time.After(delay)
+			continue
+		}
+
+		failures = 0
+		retry = nil
+		if snapshot {
+			lastSnapshot = time.Now()
+			s.status.snapshotTaken()
+		}
+	}
+}
+
+// refreshRoomInterval asks the master for the room's current interval bounds
+// and records them in the project config. An unreachable master leaves the
+// bounds from the last start in place.
+func refreshRoomInterval(cfg *types.ProjectConfig) {
+	_, room, err := master.JoinRoom(cfg.RoomID, cfg.EmailID)
+	if err != nil {
+		log.Printf("Warning: could not refresh the room's interval bounds: %v\n", err)
+		return
+	}
+	if room.Min == cfg.IntervalMin && room.Max == cfg.IntervalMax {
+		return
+	}
+	cfg.IntervalMin, cfg.IntervalMax = room.Min, room.Max
+
+	// only the bounds are written; the rest of cfg may come from flags or the environment
+	fileCfg, err := LoadProjectConfig(cfg.ProjectPath)
+	if err != nil {
+		log.Printf("Warning: could not record the room's interval bounds: %v\n", err)
+		return
+	}
+	fileCfg.IntervalMin, fileCfg.IntervalMax = room.Min, room.Max
+	if _, err := SaveProjectConfig(cfg.ProjectPath, fileCfg); err != nil {
+		log.Printf("Warning: could not record the room's interval bounds: %v\n", err)
+	}
+}
+
+// service is the state shared by every tick of one monitored project
+type service struct {
+	// project is cfg.ProjectPath, safe to read from any goroutine
+	project  string
+	cfg      types.ProjectConfig
+	flags    Overrides
+	ob       *outbox.Outbox
+	redactor *redact.Redactor
+	hooks    *cmdlib.HookServer
+	status   *statusFile
+	watcher  *watch.Watcher
+	ticker   *time.Ticker
+	// tickSlots, when set, is shared with other projects to bound concurrent ticks
+	tickSlots chan struct{}
+	// supervised services share the supervisor's master URL
+	supervised bool
+
+	events *eventLog
+	// calls carries control API operations into run's goroutine; done is
+	// closed when run returns
+	calls chan serviceCall
+	done  chan struct{}
+}
+
+// serviceCall is a control API operation run between ticks: snapshot,
+// pause, resume or reload
+type serviceCall struct {
+	op    string
+	reply chan error
+}
+
+// call runs op on the service's goroutine and waits for the result
+func (s *service) call(ctx context.Context, op string) error {
+	c := serviceCall{op: op, reply: make(chan error, 1)}
+	select {
+	case s.calls <- c:
+	case <-s.done:
+		return fmt.Errorf("%s is shutting down", s.project)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-c.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// safeTick runs tick, turning a panic in it into an error so it is retried like one
+func (s *service) safeTick(snapshot bool) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("tick panicked: %v", r)
+		}
+	}()
+	if s.tickSlots != nil {
+		s.tickSlots <- struct{}{}
+		defer func() { <-s.tickSlots }()
+	}
+	return s.tick(snapshot)
+}
+
+// tick collects new commands and, when snapshot is set, the worktree diff,
+// scrubs secrets from both and spools them for upload. Command collection
+// problems are only logged; a failed snapshot or spool is returned.
+func (s *service) tick(snapshot bool) error {
+	if snapshot {
+		diffBlob, err := controller.ComputeDiff(s.cfg)
+		if err != nil {
+			return fmt.Errorf("diff: %w", err)
+		}
+		diffBlob = s.redactor.DiffBlob(diffBlob)
+
+		if err := controller.QueueDiff(s.ob, s.cfg, diffBlob); err != nil {
+			return fmt.Errorf("diff spool: %w", err)
+		}
+		log.Printf("diff queued (%d files)\n", len(diffBlob.Changes))
+		s.events.add(s.project, "tick", "snapshot, %d files changed", len(diffBlob.Changes))
+	}
+
+	cmdDiffBlob, err := controller.ComputeCmdDiff(s.cfg, s.hooks)
+	if err != nil {
+		log.Printf("cmd diff error: %v\n", err)
+	}
+	cmdDiffBlob = s.redactor.CmdDiffBlob(cmdDiffBlob)
+
+	if err := controller.QueueCmdDiff(s.ob, s.cfg, cmdDiffBlob); err != nil {
+		return fmt.Errorf("cmd diff spool: %w", err)
+	}
+	log.Printf("cmd diff queued (%d commands)\n", len(cmdDiffBlob.Commands))
+	if len(cmdDiffBlob.Commands) > 0 {
+		s.events.add(s.project, "tick", "%d commands recorded", len(cmdDiffBlob.Commands))
+	}
+
+	log.Println("")
+	log.Println("one iteration successfull")
+	log.Println("")
+	return nil
+}
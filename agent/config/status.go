@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// agentStatus is what a running agent reports in .daemon/status.json for
+// `daemon status` and `daemon restart`. Interval is the period the agent
+// ticks at, ConfiguredInterval the one asked for before the room's bounds.
+type agentStatus struct {
+	PID                int           `json:"pid"`
+	StartedAt          time.Time     `json:"started_at"`
+	Interval           time.Duration `json:"interval"`
+	ConfiguredInterval time.Duration `json:"configured_interval"`
+	IntervalMin        time.Duration `json:"interval_min,omitempty"`
+	IntervalMax        time.Duration `json:"interval_max,omitempty"`
+	Supervised         bool          `json:"supervised,omitempty"`
+	Paused             bool          `json:"paused,omitempty"`
+	OutboxDir          string        `json:"outbox_dir,omitempty"`
+	LastSnapshot       time.Time     `json:"last_snapshot,omitzero"`
+	LastUpload         time.Time     `json:"last_upload,omitzero"`
+	LastUploadKind     string        `json:"last_upload_kind,omitempty"`
+	LastUploadError    string        `json:"last_upload_error,omitempty"`
+}
+
+// statusFile keeps agentStatus on disk; the tick loop and the outbox update it concurrently
+type statusFile struct {
+	mu     sync.Mutex
+	path   string
+	status agentStatus
+}
+
+func statusFilePath(projectPath string) string {
+	return filepath.Join(projectPath, ".daemon", "status.json")
+}
+
+func newStatusFile(cfg types.ProjectConfig, outboxDir string) *statusFile {
+	s := &statusFile{
+		path: statusFilePath(cfg.ProjectPath),
+		status: agentStatus{
+			PID:       os.Getpid(),
+			StartedAt: time.Now(),
+			OutboxDir: outboxDir,
+		},
+	}
+	s.intervalChanged(cfg)
+	return s
+}
+
+func (s *statusFile) update(fn func(*agentStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.status)
+
+	data, err := json.MarshalIndent(s.status, "", "  ")
+	if err != nil {
+		log.Printf("status encode error: %v\n", err)
+		return
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("status write error: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("status write error: %v\n", err)
+	}
+}
+
+// get returns a copy of the current status
+func (s *statusFile) get() agentStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+func (s *statusFile) intervalChanged(cfg types.ProjectConfig) {
+	s.update(func(st *agentStatus) {
+		st.Interval = EffectiveInterval(cfg)
+		st.ConfiguredInterval = cfg.Interval
+		st.IntervalMin = cfg.IntervalMin
+		st.IntervalMax = cfg.IntervalMax
+	})
+}
+
+func (s *statusFile) snapshotTaken() {
+	s.update(func(st *agentStatus) { st.LastSnapshot = time.Now() })
+}
+
+func (s *statusFile) uploadDone(kind string, err error) {
+	s.update(func(st *agentStatus) {
+		st.LastUpload = time.Now()
+		st.LastUploadKind = kind
+		st.LastUploadError = ""
+		if err != nil {
+			st.LastUploadError = err.Error()
+		}
+	})
+}
+
+func readStatusFile(projectPath string) (agentStatus, error) {
+	var st agentStatus
+	data, err := os.ReadFile(statusFilePath(projectPath))
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("failed to parse status.json: %w", err)
+	}
+	return st, nil
+}
@@ -0,0 +1,475 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/controller"
+	master "github.com/internal-hackathon-7/int-hack-7/agent/lib/master"
+	outbox "github.com/internal-hackathon-7/int-hack-7/agent/lib/outbox"
+)
+
+// ErrSupervisorRunning is returned when a second supervisor is started for the same user
+var ErrSupervisorRunning = errors.New("a supervisor is already running for this user")
+
+const (
+	// registryPoll is how often the supervisor looks for `daemon add|remove` edits
+	registryPoll = 2 * time.Second
+
+	// projectRestartDelay keeps a project whose agent keeps dying from restarting in a loop
+	projectRestartDelay = time.Minute
+
+	// maxConcurrentTicks bounds how many projects snapshot at the same time
+	maxConcurrentTicks = 2
+
+	// uploadGap spaces out the shared outbox's deliveries to the master
+	uploadGap = 250 * time.Millisecond
+)
+
+func supervisorPIDPath() (string, error) {
+	dir, err := userDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "supervisor.pid"), nil
+}
+
+// supervisor runs one service per registered project in a single process.
+// The projects share the outbox, its upload loop and the tick slots.
+type supervisor struct {
+	ob        *outbox.Outbox
+	tickSlots chan struct{}
+	events    *eventLog
+
+	mu        sync.Mutex
+	masterURL string
+	projects  map[string]*supervisedProject
+}
+
+type supervisedProject struct {
+	entry  RegistryEntry
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// guarded by supervisor.mu
+	svc    *service
+	exited time.Time
+}
+
+// SuperviseCommand handles `daemon supervise`: it monitors every project in
+// the registry until interrupted, logging to the user's supervisor.log
+func SuperviseCommand(args []string) error {
+	fs := flag.NewFlagSet("supervise", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dir, err := userDir()
+	if err != nil {
+		return fmt.Errorf("failed to find the user config directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	pidFile, err := lockPIDFile(filepath.Join(dir, "supervisor.pid"), ErrSupervisorRunning)
+	if err != nil {
+		return err
+	}
+	defer pidFile.Release()
+
+	logFile, err := os.OpenFile(filepath.Join(dir, "supervisor.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logFile.Close()
+	log.SetOutput(logFile)
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	log.Printf("Supervisor starting up, PID %d\n", os.Getpid())
+
+	ob, err := outbox.OpenDir(filepath.Join(dir, "outbox"))
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+
+	sup := &supervisor{
+		ob:        ob,
+		tickSlots: make(chan struct{}, maxConcurrentTicks),
+		events:    newEventLog(),
+		projects:  map[string]*supervisedProject{},
+	}
+	stopUploads := startUploads(ob, paced(sup.deliver, uploadGap))
+	defer stopUploads()
+
+	sockPath, err := supervisorSocketPath()
+	if err != nil {
+		return err
+	}
+	ctl, err := listenControl(sockPath, &controlServer{
+		ob:         ob,
+		events:     sup.events,
+		supervisor: true,
+		services:   sup.services,
+	})
+	if err != nil {
+		log.Printf("Warning: control API unavailable: %v\n", err)
+	} else {
+		defer ctl.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	regFile, err := registryPath()
+	if err != nil {
+		return err
+	}
+	registry := watchFiles(regFile)
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+
+	poll := time.NewTicker(registryPoll)
+	defer poll.Stop()
+	for {
+		sup.reconcile(ctx, reg)
+
+		select {
+		case <-ctx.Done():
+			log.Println("shutdown requested")
+			sup.stopAll()
+			log.Println("Supervisor shutting down.")
+			return nil
+		case <-poll.C:
+		}
+
+		if registry.changed() {
+			next, err := loadRegistry()
+			if err != nil {
+				log.Printf("registry reload REJECTED, keeping the running projects: %v\n", err)
+				continue
+			}
+			reg = next
+		}
+	}
+}
+
+// reconcile starts registered projects that aren't running, stops the ones
+// no longer registered and restarts crashed ones once projectRestartDelay passed
+func (s *supervisor) reconcile(ctx context.Context, reg Registry) {
+	want := map[string]RegistryEntry{}
+	for _, e := range reg.Projects {
+		want[e.Path] = e
+	}
+
+	var stale []*supervisedProject
+	s.mu.Lock()
+	for path, p := range s.projects {
+		e, ok := want[path]
+		crashed := !p.exited.IsZero() && time.Since(p.exited) >= projectRestartDelay
+		if !ok || e != p.entry || crashed {
+			stale = append(stale, p)
+			delete(s.projects, path)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, p := range stale {
+		p.cancel()
+		<-p.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, e := range want {
+		if _, ok := s.projects[path]; !ok {
+			s.start(ctx, e)
+		}
+	}
+}
+
+// start launches the project's service; s.mu must be held
+func (s *supervisor) start(ctx context.Context, e RegistryEntry) {
+	pctx, cancel := context.WithCancel(ctx)
+	p := &supervisedProject{entry: e, cancel: cancel, done: make(chan struct{})}
+	s.projects[e.Path] = p
+
+	go func() {
+		defer close(p.done)
+		err := s.runProject(pctx, p)
+
+		s.mu.Lock()
+		p.svc = nil
+		p.exited = time.Now()
+		s.mu.Unlock()
+
+		if err != nil {
+			log.Printf("project %s stopped, retrying in %s: %v\n", e.Path, projectRestartDelay, err)
+		} else {
+			log.Printf("project %s stopped\n", e.Path)
+		}
+	}()
+}
+
+func (s *supervisor) runProject(ctx context.Context, p *supervisedProject) error {
+	flags := Overrides{Path: p.entry.Path, Room: p.entry.RoomID}
+	cfg, err := Load(flags)
+	if err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+
+	// records in the shared outbox carry no destination, so every project uploads to one master
+	s.mu.Lock()
+	if s.masterURL == "" {
+		s.masterURL = cfg.MasterURL
+		master.SetMasterURL(cfg.MasterURL)
+	}
+	masterURL := s.masterURL
+	s.mu.Unlock()
+	if cfg.MasterURL != masterURL {
+		return fmt.Errorf("master_url %s differs from the supervisor's %s", cfg.MasterURL, masterURL)
+	}
+
+	if err := os.MkdirAll(filepath.Join(cfg.ProjectPath, ".daemon"), 0755); err != nil {
+		return fmt.Errorf("failed to create daemon directory: %w", err)
+	}
+	pidFile, err := LockPIDFile(cfg.ProjectPath)
+	if err != nil {
+		return err
+	}
+	defer pidFile.Release()
+
+	if err := EnsureGitRepo(cfg.ProjectPath); err != nil {
+		log.Printf("Warning: could not get GIT : %v\n", err)
+	}
+	if err := EnsureDaemonInGitignore(cfg.ProjectPath); err != nil {
+		log.Printf("Warning: could not update .gitignore: %v\n", err)
+	}
+
+	svc, err := newService(cfg, flags, s.ob, s.events)
+	if err != nil {
+		return err
+	}
+	defer svc.close()
+	svc.supervised = true
+	svc.tickSlots = s.tickSlots
+	svc.status.update(func(st *agentStatus) { st.Supervised = true })
+
+	s.mu.Lock()
+	p.svc = svc
+	s.mu.Unlock()
+
+	log.Printf("monitoring %s (room %s, every %s)\n", cfg.ProjectPath, cfg.RoomID, EffectiveInterval(cfg))
+	return svc.run(ctx)
+}
+
+// stopAll stops every project and waits for their last ticks
+func (s *supervisor) stopAll() {
+	s.mu.Lock()
+	projects := s.projects
+	s.projects = map[string]*supervisedProject{}
+	s.mu.Unlock()
+
+	for _, p := range projects {
+		p.cancel()
+	}
+	for _, p := range projects {
+		<-p.done
+	}
+}
+
+// deliver sends a record from the shared outbox and reports the result to
+// the project it came from
+func (s *supervisor) deliver(rec outbox.Record) error {
+	err := controller.Deliver(rec)
+
+	project := recordProject(rec)
+	uploadEvent(s.events, project, rec.Kind, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.projects {
+		if p.svc != nil && p.svc.project == project {
+			p.svc.status.uploadDone(rec.Kind, err)
+		}
+	}
+	return err
+}
+
+// recordProject reads which project a spooled record belongs to: both wire
+// kinds carry the project's path as projectName
+func recordProject(rec outbox.Record) string {
+	var body struct {
+		ProjectName string `json:"projectName"`
+	}
+	if err := json.Unmarshal(rec.Body, &body); err != nil {
+		return ""
+	}
+	return body.ProjectName
+}
+
+// services returns the projects currently running, for the control API
+func (s *supervisor) services() []*service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []*service
+	for _, p := range s.projects {
+		if p.svc != nil {
+			out = append(out, p.svc)
+		}
+	}
+	slices.SortFunc(out, func(a, b *service) int { return strings.Compare(a.project, b.project) })
+	return out
+}
+
+// paced spaces calls to send at least gap apart
+func paced(send func(outbox.Record) error, gap time.Duration) func(outbox.Record) error {
+	var last time.Time
+	return func(rec outbox.Record) error {
+		if wait := gap - time.Since(last); wait > 0 {
+			time.Sleep(wait)
+		}
+		last = time.Now()
+		return send(rec)
+	}
+}
+
+// StartSupervisorBackground launches a detached `daemon supervise` and returns its PID
+func StartSupervisorBackground() (int, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	cmd := exec.Command(exePath, "supervise")
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	cmd.Stdin = nil
+	SetDetachAttr(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start supervisor: %w", err)
+	}
+	return cmd.Process.Pid, nil
+}
+
+// AddCommand handles `daemon add <path>`: it registers an initialised
+// project with the supervisor, starting one if none is running
+func AddCommand(args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	room := fs.String("room", "", "Room ID, overrides the project's config")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: %s add [-room id] <path>", DisplayName)
+	}
+
+	cfg, err := Load(Overrides{Path: firstNonEmpty(fs.Arg(0), "."), Room: *room})
+	if err != nil {
+		return fmt.Errorf("run `%s init` in the project first:\n%w", DisplayName, err)
+	}
+	path := cfg.ProjectPath
+
+	if pid, running, err := agentRunning(path); err == nil && running && !supervisedBy(path, pid) {
+		return fmt.Errorf("an agent (PID %d) already monitors %s, stop it first with `%s stop -path %s`", pid, path, DisplayName, path)
+	}
+
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	entry := RegistryEntry{Path: path, RoomID: *room}
+	if i := reg.index(path); i >= 0 {
+		if reg.Projects[i] == entry {
+			fmt.Printf("%s is already supervised\n", path)
+		} else {
+			reg.Projects[i] = entry
+			fmt.Printf("Updated %s\n", path)
+		}
+	} else {
+		reg.Projects = append(reg.Projects, entry)
+		fmt.Printf("Added %s\n", path)
+	}
+	if err := saveRegistry(reg); err != nil {
+		return err
+	}
+
+	pidPath, err := supervisorPIDPath()
+	if err != nil {
+		return err
+	}
+	if pid, running, err := pidFileHeld(pidPath); err == nil && running {
+		fmt.Printf("Supervisor (PID %d) picks it up within %s\n", pid, registryPoll)
+		return nil
+	}
+	pid, err := StartSupervisorBackground()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started supervisor with PID %d\n", pid)
+	return nil
+}
+
+// RemoveCommand handles `daemon remove <path>`, waiting for the supervisor
+// to finish the project's last tick
+func RemoveCommand(args []string) error {
+	fs := flag.NewFlagSet("remove", flag.ExitOnError)
+	timeout := fs.Duration("timeout", defaultStopTimeout, "How long to wait for the supervisor to let go of the project")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: %s remove <path>", DisplayName)
+	}
+
+	path, err := filepath.Abs(firstNonEmpty(fs.Arg(0), "."))
+	if err != nil {
+		return fmt.Errorf("failed to resolve project path: %w", err)
+	}
+
+	reg, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	i := reg.index(path)
+	if i < 0 {
+		return fmt.Errorf("%s is not supervised", path)
+	}
+	reg.Projects = append(reg.Projects[:i], reg.Projects[i+1:]...)
+	if err := saveRegistry(reg); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s\n", path)
+
+	pid, running, err := agentRunning(path)
+	if err != nil || !running || !supervisedBy(path, pid) {
+		return nil
+	}
+	if !waitForExit(pidFilePath(path), registryPoll+*timeout) {
+		return fmt.Errorf("supervisor (PID %d) is still monitoring %s", pid, path)
+	}
+	fmt.Println("Monitoring stopped")
+	return nil
+}
+
+// supervisedBy reports whether the agent holding the project's PID file is a supervisor
+func supervisedBy(projectPath string, pid int) bool {
+	st, err := readStatusFile(projectPath)
+	return err == nil && st.Supervised && st.PID == pid
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	master "github.com/internal-hackathon-7/int-hack-7/agent/lib/master"
+	outbox "github.com/internal-hackathon-7/int-hack-7/agent/lib/outbox"
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+	"github.com/internal-hackathon-7/int-hack-7/agent/wire"
+)
+
+func TestRecordProject(t *testing.T) {
+	diff, _ := json.Marshal(wire.FromDiffBlob("room", "member", types.DiffBlob{ProjectName: "/src/a"}))
+	cmds, _ := json.Marshal(wire.FromCmdDiffBlob("room", "member", "/src/b", types.CmdDiffBlob{}))
+
+	tests := []struct {
+		rec  outbox.Record
+		want string
+	}{
+		{outbox.Record{Kind: master.KindDiffBlob, Body: diff}, "/src/a"},
+		{outbox.Record{Kind: master.KindCmdDiffBlob, Body: cmds}, "/src/b"},
+		{outbox.Record{Kind: "unknown", Body: []byte("not json")}, ""},
+	}
+	for _, tt := range tests {
+		if got := recordProject(tt.rec); got != tt.want {
+			t.Errorf("recordProject(%s) = %q, want %q", tt.rec.Kind, got, tt.want)
+		}
+	}
+}
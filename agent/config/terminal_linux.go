@@ -0,0 +1,14 @@
+package config
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// isTerminal reports whether f is an interactive terminal; /dev/null is a
+// character device too, so the mode bits alone don't tell
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	return err == nil
+}
@@ -7,26 +7,45 @@ import (
 	"github.com/internal-hackathon-7/int-hack-7/agent/types"
 )
 
-func ComputeCmdDiff(projectPath string) (types.CmdDiffBlob, error) {
+// ComputeCmdDiff returns the commands run in the project since the last tick.
+// Commands reported by shell hooks are used once any hook has spoken; otherwise
+// new lines of the history file are read, and failing to find it is an error.
+func ComputeCmdDiff(cfg types.ProjectConfig, hooks *lib.HookServer) (types.CmdDiffBlob, error) {
 	var cmdDiff types.CmdDiffBlob
+	projectPath := cfg.ProjectPath
+	filter := lib.NewCommandFilter(cfg)
 
-	currentHistFile, err := lib.DetectHistoryFile(projectPath)
-	if err != nil {
+	// drain first: hooks keep working where the history file can't be found
+	var hookEntries []types.CommandEntry
+	hooksActive := false
+	if hooks != nil {
+		hookEntries, hooksActive = hooks.Drain()
+	}
+
+	currentHistFile, collector, err := lib.DetectHistoryFile(cfg)
+	if err != nil && !hooksActive {
 		log.Print("error in finding history file")
 		return cmdDiff, err
 	}
 
-	cmdDiff, err = lib.DiffCmdHistory(projectPath, currentHistFile)
-	if err != nil {
-		log.Println("error diffing : ", err)
+	if hooksActive {
+		cmdDiff.Commands = filter.Apply(hookEntries)
+
+		// keep the history cursor current so falling back later doesn't replay old commands
+		if err == nil {
+			if _, err := lib.ReadHistory(projectPath, collector, currentHistFile); err != nil {
+				log.Println("error reading command history: ", err)
+			}
+		}
 		return cmdDiff, nil
 	}
 
-	err = lib.UpdateHistory(projectPath, currentHistFile)
+	entries, err := lib.ReadHistory(projectPath, collector, currentHistFile)
 	if err != nil {
-		log.Print("error finding latest command history")
+		log.Println("error reading command history: ", err)
 		return cmdDiff, nil
 	}
 
+	cmdDiff.Commands = filter.Apply(entries)
 	return cmdDiff, nil
 }
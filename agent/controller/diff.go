@@ -1,31 +1,38 @@
 package controller
 
 import (
-	"log"
+	"fmt"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	lib "github.com/internal-hackathon-7/int-hack-7/agent/lib/git"
 	"github.com/internal-hackathon-7/int-hack-7/agent/types"
 )
 
-func ComputeDiff(projectPath string) (types.DiffBlob, error) {
+// ComputeDiff snapshots the worktree and diffs it against the previous snapshot
+func ComputeDiff(cfg types.ProjectConfig) (types.DiffBlob, error) {
 	var diffBlob types.DiffBlob
 
-	oldHash, err := GetLastHash(projectPath)
+	oldHash, err := GetLastHash(cfg.ProjectPath, cfg.EmailID)
 	if err != nil {
-		log.Print("error finding last hash")
-		return diffBlob, nil
+		return diffBlob, fmt.Errorf("error finding last hash: %w", err)
 	}
 
-	newHash, err := GetNewHash(projectPath)
+	newHash, err := GetNewHash(cfg.ProjectPath, cfg.EmailID, cfg.DaemonIgnore)
 	if err != nil {
-		log.Print("error getting new hash")
+		return diffBlob, fmt.Errorf("error getting new hash: %w", err)
+	}
+
+	// the first snapshot is the baseline; there is nothing to diff it against
+	if oldHash == plumbing.ZeroHash.String() {
 		return diffBlob, nil
 	}
 
-	diffBlob, err = lib.DiffWithHash(projectPath, oldHash, newHash)
+	diffBlob, err = lib.DiffWithHash(cfg.ProjectPath, oldHash, newHash, lib.DiffOptions{
+		RenameThreshold: cfg.RenameThreshold,
+		DaemonIgnore:    cfg.DaemonIgnore,
+	})
 	if err != nil {
-		log.Println("error diffing : ", err)
-		return diffBlob, nil
+		return diffBlob, fmt.Errorf("error diffing: %w", err)
 	}
 
 	return diffBlob, nil
@@ -1,60 +1,25 @@
 package controller
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/go-git/go-git/v5/plumbing"
 	lib "github.com/internal-hackathon-7/int-hack-7/agent/lib/git"
 )
 
-func GetLastHash(projectPath string) (string, error) {
-	stateFile := filepath.Join(projectPath, ".daemon", "state.txt")
-
-	f, err := os.Open(stateFile)
+func GetLastHash(projectPath, email string) (string, error) {
+	hash, err := lib.LastSnapshotTree(projectPath, email)
 	if err != nil {
-		// File not found or unreadable — fallback to ZeroHash
-		return plumbing.ZeroHash.String(), nil
-	}
-	defer f.Close()
-
-	var lastLine string
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			lastLine = line
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return plumbing.ZeroHash.String(), nil
-	}
-
-	if lastLine == "" {
+		// unreadable ref — fallback to ZeroHash
 		return plumbing.ZeroHash.String(), nil
 	}
-
-	parts := strings.Fields(lastLine)
-	if len(parts) < 2 {
-		return plumbing.ZeroHash.String(), nil
-	}
-
-	hash := strings.TrimSpace(parts[len(parts)-1])
-	if hash == "" {
-		return plumbing.ZeroHash.String(), nil
-	}
-
-	return hash, nil
+	return hash.String(), nil
 }
 
-func GetNewHash(projectPath string) (string, error) {
-	hash, err := lib.CommitSnapshot(projectPath)
+func GetNewHash(projectPath, email string, daemonIgnore []string) (string, error) {
+	hash, err := lib.CommitSnapshot(projectPath, email, daemonIgnore)
 	if err != nil {
-		return plumbing.ZeroHash.String(), fmt.Errorf("error taking the snapshot : %v", err)
+		return plumbing.ZeroHash.String(), fmt.Errorf("error taking the snapshot : %w", err)
 	}
 	return hash.String(), nil
 }
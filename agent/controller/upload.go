@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"log"
+
+	master "github.com/internal-hackathon-7/int-hack-7/agent/lib/master"
+	outbox "github.com/internal-hackathon-7/int-hack-7/agent/lib/outbox"
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+	"github.com/internal-hackathon-7/int-hack-7/agent/wire"
+)
+
+// QueueDiff spools a tick's DiffBlob for delivery, skipping ticks with no changes
+func QueueDiff(ob *outbox.Outbox, cfg types.ProjectConfig, diffBlob types.DiffBlob) error {
+	if len(diffBlob.Changes) == 0 {
+		log.Println("no file changes this tick, skipping diff upload")
+		return nil
+	}
+
+	return ob.Append(master.KindDiffBlob, wire.FromDiffBlob(cfg.RoomID, memberID(cfg), diffBlob))
+}
+
+// QueueCmdDiff spools a tick's CmdDiffBlob for delivery, skipping ticks with no commands
+func QueueCmdDiff(ob *outbox.Outbox, cfg types.ProjectConfig, cmdDiffBlob types.CmdDiffBlob) error {
+	if len(cmdDiffBlob.Commands) == 0 {
+		log.Println("no new commands this tick, skipping cmd diff upload")
+		return nil
+	}
+
+	return ob.Append(master.KindCmdDiffBlob, wire.FromCmdDiffBlob(cfg.RoomID, memberID(cfg), cfg.ProjectPath, cmdDiffBlob))
+}
+
+// Deliver sends one outbox record to the master; it is the outbox's send func
+func Deliver(rec outbox.Record) error {
+	return master.Deliver(rec.Kind, rec.Body)
+}
+
+// memberID identifies this agent to the master; configs written before joinRoom returned a googleId fall back to the email
+func memberID(cfg types.ProjectConfig) string {
+	if cfg.MemberID != "" {
+		return cfg.MemberID
+	}
+	return cfg.EmailID
+}
@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+type bashCollector struct{}
+
+func init() { RegisterCollector(bashCollector{}) }
+
+func (bashCollector) Name() string { return "bash" }
+
+func (bashCollector) HistoryFile() (string, error) { return homeFile(".bash_history") }
+
+func (bashCollector) Parse(lines []string, fallback time.Time) []types.CommandEntry {
+	return parseBashLines(lines, fallback)
+}
+
+// parseBashLines groups bash history lines under the "#<epoch>" comments that
+// bash writes when HISTTIMEFORMAT is set. Lines up to the next timestamp belong
+// to the same command (multi-line commands with lithist). Without timestamps
+// every line is its own command stamped with fallback.
+func parseBashLines(lines []string, fallback time.Time) []types.CommandEntry {
+	var entries []types.CommandEntry
+
+	var current *types.CommandEntry
+	flush := func() {
+		if current != nil && current.Command != "" {
+			entries = append(entries, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if ts, ok := bashTimestamp(line); ok {
+			flush()
+			current = &types.CommandEntry{
+				Timestamp: time.Unix(ts, 0),
+				ExitCode:  types.UnknownExitCode,
+			}
+			continue
+		}
+
+		if current == nil {
+			entries = append(entries, types.CommandEntry{
+				Timestamp: fallback,
+				Command:   line,
+				ExitCode:  types.UnknownExitCode,
+			})
+			continue
+		}
+
+		if current.Command != "" {
+			current.Command += "\n"
+		}
+		current.Command += line
+	}
+	flush()
+
+	return entries
+}
+
+// bashTimestamp reports whether line is a "#<epoch>" history timestamp
+func bashTimestamp(line string) (int64, bool) {
+	digits, ok := strings.CutPrefix(line, "#")
+	if !ok || digits == "" {
+		return 0, false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	ts, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return ts, true
+}
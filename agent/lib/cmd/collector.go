@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// Collector reads the history file of one shell
+type Collector interface {
+	// Name is the shell name as found in $SHELL and default_shell
+	Name() string
+	// HistoryFile returns the path the shell writes its history to
+	HistoryFile() (string, error)
+	// Parse turns new history lines, as written and without line endings, into
+	// entries. Entries the shell did not timestamp get fallback.
+	Parse(lines []string, fallback time.Time) []types.CommandEntry
+}
+
+var collectors = map[string]Collector{}
+
+// RegisterCollector makes c available under its name and any aliases
+func RegisterCollector(c Collector, aliases ...string) {
+	collectors[c.Name()] = c
+	for _, alias := range aliases {
+		collectors[alias] = c
+	}
+}
+
+// CollectorFor returns the collector for a shell name such as "zsh" or "pwsh.exe"
+func CollectorFor(shellName string) (Collector, error) {
+	name := strings.TrimSuffix(strings.ToLower(shellName), ".exe")
+	if c, ok := collectors[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unsupported shell: %s", shellName)
+}
+
+// dataHome returns $XDG_DATA_HOME, defaulting to ~/.local/share
+func dataHome() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "share"), nil
+}
+
+// homeFile returns $HISTFILE when exported, otherwise name in the home directory
+func homeFile(name string) (string, error) {
+	if histFile := os.Getenv("HISTFILE"); histFile != "" {
+		return histFile, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, name), nil
+}
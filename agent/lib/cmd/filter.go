@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// CommandFilter keeps only commands that belong to the monitored project
+// and pass the allow/deny prefix lists.
+type CommandFilter struct {
+	roots        []string
+	allow        []string
+	deny         []string
+	unattributed bool
+}
+
+func NewCommandFilter(cfg types.ProjectConfig) *CommandFilter {
+	f := &CommandFilter{
+		allow:        cfg.CommandAllow,
+		deny:         cfg.CommandDeny,
+		unattributed: cfg.RecordUnattributed,
+	}
+
+	f.roots = append(f.roots, resolveDir(cfg.ProjectPath, ""))
+	for _, extra := range cfg.ExtraPaths {
+		f.roots = append(f.roots, resolveDir(extra, cfg.ProjectPath))
+	}
+
+	return f
+}
+
+// unattributedWarning is logged once per process: without hooks, bash, zsh
+// and pwsh history never says where a command ran
+var unattributedWarning sync.Once
+
+// Apply returns the entries that Keep accepts
+func (f *CommandFilter) Apply(entries []types.CommandEntry) []types.CommandEntry {
+	kept := entries[:0:0]
+	dropped := 0
+	for _, e := range entries {
+		if f.Keep(e) {
+			kept = append(kept, e)
+		} else if !f.unattributed && !f.attributed(e) {
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		unattributedWarning.Do(func() {
+			log.Printf("dropped %d history commands with no working directory; install the shell hook (`daemon shell-hook <shell>`) or set record_unattributed: true to record them\n", dropped)
+		})
+	}
+	return kept
+}
+
+// Keep reports whether e ran inside the project and isn't excluded by prefix.
+// The hook cwd decides where it ran; failing that, any path the command
+// referenced (fish) does.
+func (f *CommandFilter) Keep(e types.CommandEntry) bool {
+	command := strings.TrimSpace(e.Command)
+	if hasCommandPrefix(command, f.deny) {
+		return false
+	}
+	if len(f.allow) > 0 && !hasCommandPrefix(command, f.allow) {
+		return false
+	}
+
+	if e.Cwd != "" {
+		return f.underRoot(e.Cwd)
+	}
+
+	for _, p := range e.Paths {
+		if (filepath.IsAbs(p) && f.underRoot(p)) || f.existsUnderRoot(p) {
+			return true
+		}
+	}
+
+	return !f.attributed(e) && f.unattributed
+}
+
+// attributed reports whether e carries a directory: a hook cwd or an
+// absolute path. Relative paths only count when they exist in a root.
+func (f *CommandFilter) attributed(e types.CommandEntry) bool {
+	if e.Cwd != "" {
+		return true
+	}
+	for _, p := range e.Paths {
+		if filepath.IsAbs(p) || f.existsUnderRoot(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// existsUnderRoot resolves a relative path fish recorded against each root.
+// fish keeps paths as typed, so a relative one is taken to mean the project
+// when it names a file there.
+func (f *CommandFilter) existsUnderRoot(path string) bool {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return false
+	}
+	for _, root := range f.roots {
+		full := filepath.Join(root, path)
+		if _, err := os.Lstat(full); err == nil && f.underRoot(full) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *CommandFilter) underRoot(path string) bool {
+	path = resolveDir(path, "")
+	for _, root := range f.roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCommandPrefix matches whole words, so "ssh" matches "ssh host" but not "sshfs"
+func hasCommandPrefix(command string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+		if command == prefix || strings.HasPrefix(command, prefix+" ") || strings.HasPrefix(command, prefix+"\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDir makes dir absolute (relative to base, with ~ expanded) and
+// follows symlinks when it exists
+func resolveDir(dir, base string) string {
+	if rest, ok := strings.CutPrefix(dir, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = home + rest
+		}
+	}
+	if !filepath.IsAbs(dir) && base != "" {
+		dir = filepath.Join(base, dir)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	return dir
+}
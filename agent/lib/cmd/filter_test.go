@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+func TestCommandFilterAttribution(t *testing.T) {
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "main.go"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	elsewhere := t.TempDir()
+
+	tests := []struct {
+		name         string
+		entry        types.CommandEntry
+		unattributed bool
+		want         bool
+	}{
+		{"hook cwd inside", types.CommandEntry{Command: "ls", Cwd: project}, false, true},
+		{"hook cwd outside", types.CommandEntry{Command: "ls", Cwd: elsewhere}, true, false},
+		{"absolute fish path", types.CommandEntry{Command: "vim x", Paths: []string{filepath.Join(project, "x")}}, false, true},
+		{"relative fish path in project", types.CommandEntry{Command: "go run main.go", Paths: []string{"main.go"}}, false, true},
+		{"relative fish path escaping", types.CommandEntry{Command: "cat ../x", Paths: []string{"../" + filepath.Base(elsewhere)}}, false, false},
+		{"history without cwd", types.CommandEntry{Command: "make"}, false, false},
+		{"history without cwd, recorded", types.CommandEntry{Command: "make"}, true, true},
+		{"relative path elsewhere, recorded", types.CommandEntry{Command: "cat nope", Paths: []string{"nope"}}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewCommandFilter(types.ProjectConfig{ProjectPath: project, RecordUnattributed: tt.unattributed})
+			if got := f.Keep(tt.entry); got != tt.want {
+				t.Errorf("Keep = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
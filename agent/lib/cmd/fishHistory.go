@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+type fishCollector struct{}
+
+func init() { RegisterCollector(fishCollector{}) }
+
+func (fishCollector) Name() string { return "fish" }
+
+// HistoryFile honours an exported $fish_history session name
+func (fishCollector) HistoryFile() (string, error) {
+	dir, err := dataHome()
+	if err != nil {
+		return "", err
+	}
+	session := "fish"
+	if name := os.Getenv("fish_history"); name != "" {
+		session = name
+	}
+	return filepath.Join(dir, "fish", session+"_history"), nil
+}
+
+// Parse reads fish's YAML-like entries: a "- cmd:" line followed by indented
+// "when:" and "paths:" keys.
+func (fishCollector) Parse(lines []string, fallback time.Time) []types.CommandEntry {
+	var entries []types.CommandEntry
+
+	var current *types.CommandEntry
+	inPaths := false
+	flush := func() {
+		if current != nil && current.Command != "" {
+			entries = append(entries, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if cmd, ok := strings.CutPrefix(line, "- cmd: "); ok {
+			flush()
+			inPaths = false
+			current = &types.CommandEntry{
+				Timestamp: fallback,
+				Command:   unescapeFish(cmd),
+				ExitCode:  types.UnknownExitCode,
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "when: "):
+			inPaths = false
+			if ts, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "when: ")), 10, 64); err == nil {
+				current.Timestamp = time.Unix(ts, 0)
+			}
+		case line == "paths:":
+			inPaths = true
+		case inPaths && strings.HasPrefix(line, "- "):
+			current.Paths = append(current.Paths, unescapeFish(strings.TrimPrefix(line, "- ")))
+		default:
+			inPaths = false
+		}
+	}
+	flush()
+
+	return entries
+}
+
+// unescapeFish undoes fish's history escaping of newlines and backslashes
+func unescapeFish(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
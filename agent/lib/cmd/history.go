@@ -1,339 +1,237 @@
 package lib
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
-	"time"
 
 	"github.com/internal-hackathon-7/int-hack-7/agent/types"
-	"github.com/internal-hackathon-7/int-hack-7/agent/utils"
-	"gopkg.in/yaml.v3"
 )
 
-func DiffCmdHistory(projectPath string, currentHistory string) (types.CmdDiffBlob, error) {
-	var diff types.CmdDiffBlob
+// tailSize is how much of the already-read history is kept to recognise it
+// again after the file is rewritten
+const tailSize = 256
 
-	var oldHistory string
+// minTailLines is the shortest tail suffix trusted to identify a position
+const minTailLines = 3
 
-	oldHistory, _ = FindOldHistoryFile(projectPath)
+// historyCursor records how far the live history file has been read. Inode
+// and Tail tell an append apart from a rotation or rewrite.
+type historyCursor struct {
+	Path   string `json:"path"`
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+	Tail   []byte `json:"tail"`
+}
 
-	data, err := ParseHistoryDiff(oldHistory, currentHistory)
+// ReadHistory returns the commands appended to histFile since the last call.
+// The first call only records the current end of the file.
+func ReadHistory(projectPath string, collector Collector, histFile string) ([]types.CommandEntry, error) {
+	f, err := os.Open(histFile)
 	if err != nil {
-		log.Print("error parsing history diff")
-		return diff, err
+		return nil, fmt.Errorf("failed to open history file: %w", err)
 	}
+	defer f.Close()
 
-	diff.Commands = data
-
-	return diff, nil
-}
-
-func FindOldHistoryFile(projectPath string) (string, error) {
-	shellDir := filepath.Join(projectPath, ".daemon", "shell")
-
-	entries, err := os.ReadDir(shellDir)
+	info, err := f.Stat()
 	if err != nil {
-		return "", fmt.Errorf("failed to read shell directory: %v", err)
+		return nil, fmt.Errorf("failed to stat history file: %w", err)
 	}
+	inode := fileInode(info)
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	cur, found, err := loadHistoryCursor(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if !found || cur.Path != histFile {
+		cur, err = startHistoryCursor(projectPath, f, info.Size())
+		if err != nil {
+			return nil, err
 		}
-		name := entry.Name()
-		if strings.HasSuffix(name, "_history") {
-			return filepath.Join(shellDir, name), nil
+		cur.Path = histFile
+		cur.Inode = inode
+		if cur.Offset == info.Size() {
+			return nil, saveHistoryCursor(projectPath, cur)
 		}
 	}
 
-	return "", fmt.Errorf("no history file found in %s", shellDir)
-}
+	start, err := resumeOffset(f, info.Size(), inode, cur)
+	if err != nil {
+		return nil, err
+	}
 
-func DetectHistoryFile(projectPath string) (string, error) {
-	configPath := filepath.Join(projectPath, ".daemon", "config.yaml")
+	data := make([]byte, info.Size()-start)
+	if _, err := f.ReadAt(data, start); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
 
-	var shellName string
-	var cfg types.ProjectConfig
+	// leave a line the shell is still writing for the next call
+	end := bytes.LastIndexByte(data, '\n') + 1
+	data = data[:end]
 
-	// Step 1: Try reading config.yaml
-	if data, err := os.ReadFile(configPath); err == nil {
-		if err := yaml.Unmarshal(data, &cfg); err == nil {
-			if cfg.DefaultShell != "" {
-				shellName = cfg.DefaultShell
-				log.Println("Using defaultShell from config.yaml:", shellName)
-			} else {
-				log.Println("config.yaml found but defaultShell missing; will detect and update.")
-			}
-		} else {
-			log.Printf("Failed to parse config.yaml, will recreate: %v", err)
-		}
-	} else {
-		log.Printf("No config.yaml found, will create one: %v", err)
+	next := historyCursor{Path: histFile, Inode: inode, Offset: start + int64(end)}
+	if next.Tail, err = readTail(f, next.Offset); err != nil {
+		return nil, err
+	}
+	if err := saveHistoryCursor(projectPath, next); err != nil {
+		return nil, err
 	}
 
-	// Step 2: Detect shell if not found in config
-	if shellName == "" {
-		shellPath := os.Getenv("SHELL")
-		if shellPath == "" {
-			out, err := exec.Command("which", "bash").Output()
-			if err != nil {
-				return "", fmt.Errorf("failed to detect shell: %v", err)
-			}
-			shellPath = strings.TrimSpace(string(out))
+	// lines go to the collector untrimmed, blank lines can be part of a command
+	var lines []string
+	if len(data) > 0 {
+		for _, ln := range strings.Split(string(data[:len(data)-1]), "\n") {
+			lines = append(lines, strings.TrimSuffix(ln, "\r"))
 		}
-		shellName = filepath.Base(shellPath)
-		log.Println("Detected shell:", shellName)
-
-		// ✅ Update config.yaml with detected shell
-		cfg.DefaultShell = shellName
+	}
 
-		// Ensure .daemon directory exists
-		if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
-			return "", fmt.Errorf("failed to create .daemon directory: %v", err)
-		}
+	// entries without their own timestamp ran some time before the last write
+	return collector.Parse(lines, info.ModTime()), nil
+}
 
-		outData, err := yaml.Marshal(&cfg)
+// resumeOffset finds where unread history starts. Appends continue at the
+// cursor; after a rotation or rewrite the old tail is searched for and
+// reading resumes behind its last occurrence, or at the start if it's gone.
+func resumeOffset(f *os.File, size int64, inode uint64, cur historyCursor) (int64, error) {
+	if cur.Inode == inode && cur.Offset >= 0 && cur.Offset <= size {
+		tail, err := readTail(f, cur.Offset)
 		if err != nil {
-			return "", fmt.Errorf("failed to marshal config.yaml: %v", err)
+			return 0, err
 		}
-
-		if err := os.WriteFile(configPath, outData, 0644); err != nil {
-			return "", fmt.Errorf("failed to write config.yaml: %v", err)
+		if bytes.Equal(tail, cur.Tail) {
+			return cur.Offset, nil
 		}
-
-		log.Printf("Updated %s with defaultShell: %s", configPath, shellName)
 	}
 
-	// Step 3: Determine history file path
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home dir: %v", err)
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("failed to read history file: %w", err)
 	}
 
-	var histFile string
-	switch shellName {
-	case "bash":
-		histFile = filepath.Join(homeDir, ".bash_history")
-	case "zsh":
-		histFile = filepath.Join(homeDir, ".zsh_history")
-	default:
-		return "", fmt.Errorf("unsupported shell: %s", shellName)
+	// shells trim old entries when rewriting, so shorter suffixes of the tail
+	// are tried too, down to a few lines
+	for tail := cur.Tail; bytes.Count(tail, []byte("\n")) >= minTailLines; {
+		if i := bytes.LastIndex(data, tail); i >= 0 {
+			log.Printf("history file %s was rewritten, resuming after the last read entry\n", cur.Path)
+			return int64(i + len(tail)), nil
+		}
+		nl := bytes.IndexByte(tail, '\n')
+		tail = tail[nl+1:]
 	}
 
-	return histFile, nil
+	log.Printf("history file %s was truncated or rotated, reading from the start\n", cur.Path)
+	return 0, nil
 }
 
-func UpdateHistory(projectPath string, histFile string) error {
-	// Step 3: Prepare destination folder
-	destDir := filepath.Join(projectPath, ".daemon", "shell")
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return fmt.Errorf("failed to create destination dir: %v", err)
-	}
+// startHistoryCursor places a new cursor at the end of the file, or where a
+// copy left by older agents (.daemon/shell/*_history) ends, so the switch
+// neither replays nor loses commands. The copies are removed afterwards.
+func startHistoryCursor(projectPath string, f *os.File, size int64) (historyCursor, error) {
+	cur := historyCursor{Offset: size}
 
-	// Step 4: Copy history file
-	destFile := filepath.Join(destDir, filepath.Base(histFile))
-	if err := utils.CopyFileShort(histFile, destFile); err != nil {
-		return fmt.Errorf("failed to copy history file: %v", err)
+	copies, _ := filepath.Glob(filepath.Join(projectPath, ".daemon", "shell", "*_history"))
+	for _, copyPath := range copies {
+		data, err := os.ReadFile(copyPath)
+		if err == nil && len(data) > 0 {
+			// an impossible cursor forces resumeOffset to search for the tail
+			cur = historyCursor{Offset: -1, Tail: data[max(0, len(data)-tailSize):]}
+		}
+		os.Remove(copyPath)
 	}
 
-	log.Printf("Copied %s to %s\n", histFile, destFile)
-
-	// cmdArray, err = ParseHistoryFile(destFile)
-	// if err != nil {
-	// 	log.Printf("Error parsing the history file")
-	// 	return cmdArray, err
-	// }
-
-	return nil
-}
-
-func ParseHistoryDiff(oldPath, newPath string) ([]types.CommandEntry, error) {
-	// Helper: read non-empty, trimmed lines from a file
-	readLines := func(path string) ([]string, error) {
-		data, err := os.ReadFile(path)
+	if cur.Offset == size {
+		tail, err := readTail(f, size)
 		if err != nil {
-			return nil, err
+			return cur, err
 		}
-		raw := strings.Split(string(data), "\n")
-		out := make([]string, 0, len(raw))
-		for _, ln := range raw {
-			ln = strings.TrimSpace(ln)
-			if ln != "" {
-				out = append(out, ln)
-			}
-		}
-		return out, nil
+		cur.Tail = tail
 	}
+	return cur, nil
+}
 
-	// ✅ If oldPath is empty → do nothing, return empty result
-	if oldPath == "" {
-		return nil, nil
+// readTail returns up to tailSize bytes ending at offset
+func readTail(f *os.File, offset int64) ([]byte, error) {
+	start := max(0, offset-tailSize)
+	tail := make([]byte, offset-start)
+	if _, err := f.ReadAt(tail, start); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
 	}
+	return tail, nil
+}
 
-	// Read new file (required)
-	newLines, err := readLines(newPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read new history file: %w", err)
-	}
+func historyCursorPath(projectPath string) string {
+	return filepath.Join(projectPath, ".daemon", "shell", "cursor.json")
+}
 
-	// Read old file (ignore error if missing)
-	oldLines, _ := readLines(oldPath)
+func loadHistoryCursor(projectPath string) (historyCursor, bool, error) {
+	var cur historyCursor
 
-	// Build frequency map (multiset) of old lines
-	oldCount := make(map[string]int, len(oldLines))
-	for _, ln := range oldLines {
-		oldCount[ln]++
+	data, err := os.ReadFile(historyCursorPath(projectPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return cur, false, nil
 	}
-
-	// Collect lines present in new but not in old
-	diffLines := make([]string, 0, len(newLines))
-	for _, ln := range newLines {
-		if c := oldCount[ln]; c > 0 {
-			oldCount[ln] = c - 1 // consume one instance
-			continue
-		}
-		diffLines = append(diffLines, ln)
+	if err != nil {
+		return cur, false, fmt.Errorf("failed to read history cursor: %w", err)
 	}
 
-	entries := parseLinesToEntries(diffLines)
-	return entries, nil
+	if err := json.Unmarshal(data, &cur); err != nil {
+		// start over rather than get stuck on a damaged cursor
+		log.Printf("invalid history cursor, starting from the end: %v\n", err)
+		return cur, false, nil
+	}
+	return cur, true, nil
 }
 
-func parseLinesToEntries(lines []string) []types.CommandEntry {
-	var entries []types.CommandEntry
-
-	for _, line := range lines {
-		// Skip malformed or empty lines
-		if !strings.HasPrefix(line, ":") {
-			continue
-		}
-
-		// Example format: ": 1762008614:0;clear"
-		parts := strings.SplitN(line, ";", 2)
-		if len(parts) != 2 {
-			continue
-		}
-
-		meta := strings.TrimPrefix(parts[0], ":")
-		command := strings.TrimSpace(parts[1])
+func saveHistoryCursor(projectPath string, cur historyCursor) error {
+	path := historyCursorPath(projectPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create shell dir: %w", err)
+	}
 
-		metaParts := strings.Split(meta, ":")
-		if len(metaParts) < 2 {
-			continue
-		}
+	data, err := json.Marshal(cur)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history cursor: %w", err)
+	}
 
-		// Parse timestamp
-		timestampUnix, err := strconv.ParseInt(strings.TrimSpace(metaParts[0]), 10, 64)
-		if err != nil {
-			continue
-		}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write history cursor: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
 
-		// Parse exit code
-		exitCode, err := strconv.Atoi(strings.TrimSpace(metaParts[1]))
-		if err != nil {
-			exitCode = -1
+// DetectHistoryFile returns the live history file and the collector for the
+// shell that writes it: default_shell from the config, else $SHELL.
+func DetectHistoryFile(cfg types.ProjectConfig) (string, Collector, error) {
+	shellName := cfg.DefaultShell
+	if shellName == "" {
+		shellPath := os.Getenv("SHELL")
+		if shellPath == "" {
+			out, err := exec.Command("which", "bash").Output()
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to detect shell: %v", err)
+			}
+			shellPath = strings.TrimSpace(string(out))
 		}
+		shellName = filepath.Base(shellPath)
+	}
 
-		entry := types.CommandEntry{
-			Timestamp: time.Unix(timestampUnix, 0),
-			Command:   command,
-			ExitCode:  exitCode,
-		}
+	collector, err := CollectorFor(shellName)
+	if err != nil {
+		return "", nil, err
+	}
 
-		entries = append(entries, entry)
+	histFile, err := collector.HistoryFile()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to locate %s history: %w", shellName, err)
 	}
 
-	return entries
+	return histFile, collector, nil
 }
-
-// func ParseHistoryDiff(oldPath, newPath string) ([]types.CommandEntry, error) {
-// 	var data []types.CommandEntry
-// 	if oldPath == "" {
-// 		return data, nil
-// 	} else {
-// 		// Helper function to read lines from a file
-// 		readLines := func(path string) ([]string, error) {
-// 			data, err := os.ReadFile(path)
-// 			if err != nil {
-// 				return nil, err
-// 			}
-// 			lines := strings.Split(string(data), "\n")
-// 			var clean []string
-// 			for _, line := range lines {
-// 				line = strings.TrimSpace(line)
-// 				if line != "" {
-// 					clean = append(clean, line)
-// 				}
-// 			}
-// 			return clean, nil
-// 		}
-
-// 		// Read both files
-// 		oldLines, _ := readLines(oldPath) // ignore error if old file missing
-// 		newLines, err := readLines(newPath)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("failed to read new history file: %v", err)
-// 		}
-
-// 		// Find the diff (new lines not in old)
-// 		startIdx := 0
-// 		if len(oldLines) > 0 && len(newLines) > len(oldLines) {
-// 			// assume history grows by appending
-// 			startIdx = len(newLines) - len(oldLines)
-// 			// adjust if partial overlap (simple fallback)
-// 			if startIdx < 0 {
-// 				startIdx = 0
-// 			}
-// 		}
-
-// 		diffLines := newLines[startIdx:]
-// 		if len(diffLines) == 0 {
-// 			return nil, nil // no new commands
-// 		}
-
-// 		// Parse diff lines
-// 		var entries []types.CommandEntry
-// 		for _, line := range diffLines {
-// 			if !strings.HasPrefix(line, ":") {
-// 				continue // skip malformed lines
-// 			}
-
-// 			parts := strings.SplitN(line, ";", 2)
-// 			if len(parts) != 2 {
-// 				continue
-// 			}
-
-// 			meta := strings.TrimPrefix(parts[0], ":")
-// 			command := strings.TrimSpace(parts[1])
-
-// 			metaParts := strings.Split(meta, ":")
-// 			if len(metaParts) < 2 {
-// 				continue
-// 			}
-
-// 			timestampUnix, err := strconv.ParseInt(strings.TrimSpace(metaParts[0]), 10, 64)
-// 			if err != nil {
-// 				continue
-// 			}
-
-// 			exitCode, err := strconv.Atoi(strings.TrimSpace(metaParts[1]))
-// 			if err != nil {
-// 				exitCode = -1
-// 			}
-
-// 			entry := types.CommandEntry{
-// 				Timestamp: time.Unix(timestampUnix, 0),
-// 				Command:   command,
-// 				ExitCode:  exitCode,
-// 			}
-// 			entries = append(entries, entry)
-// 		}
-
-// 		return entries, nil
-// 	}
-// }
@@ -0,0 +1,186 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// HookEvent is what `daemon hook-report` sends for every finished command.
+// Times are unix seconds with a fractional part.
+type HookEvent struct {
+	Command string  `json:"command"`
+	Cwd     string  `json:"cwd"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Exit    int     `json:"exit"`
+}
+
+// HookServer collects command events from shell hooks on a unix socket.
+// Shells don't know which project a command belongs to, so every running
+// agent listens in one per-user directory and each report goes to all of them.
+type HookServer struct {
+	ln      net.Listener
+	path    string
+	mu      sync.Mutex
+	pending []types.CommandEntry
+	active  bool
+}
+
+// HookSocketDir is the per-user directory holding every agent's sockets:
+// $XDG_RUNTIME_DIR/daemon, else daemon-<uid> in the temp dir. Every command
+// line is sent to whatever listens there, so a directory this user doesn't
+// own, or that others can enter, is refused.
+func HookSocketDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("daemon-%d", os.Getuid()))
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		dir = filepath.Join(runtime, "daemon")
+	}
+
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("failed to create hook socket dir: %w", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to check hook socket dir: %w", err)
+	}
+	if err := checkPrivateDir(info); err != nil {
+		return "", fmt.Errorf("refusing hook socket dir %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// ProjectSocketPath is projectPath's socket named kind in HookSocketDir.
+// The path is hashed so the name stays under the unix socket length limit
+// however deep the project is.
+func ProjectSocketPath(kind, projectPath string) (string, error) {
+	dir, err := HookSocketDir()
+	if err != nil {
+		return "", err
+	}
+	abs, _ := filepath.Abs(projectPath)
+	sum := sha1.Sum([]byte(abs))
+	return filepath.Join(dir, kind+"-"+hex.EncodeToString(sum[:6])+".sock"), nil
+}
+
+func ListenHooks(projectPath string) (*HookServer, error) {
+	path, err := ProjectSocketPath("hook", projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// a socket left behind by a crashed agent blocks Listen
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	h := &HookServer{ln: ln, path: path}
+	go h.serve()
+	return h, nil
+}
+
+// Drain returns the commands reported since the last call, and whether any
+// hook has reported at all since the agent started
+func (h *HookServer) Drain() ([]types.CommandEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := h.pending
+	h.pending = nil
+	return entries, h.active
+}
+
+func (h *HookServer) Close() error {
+	err := h.ln.Close()
+	os.Remove(h.path)
+	return err
+}
+
+func (h *HookServer) serve() {
+	for {
+		conn, err := h.ln.Accept()
+		if err != nil {
+			return
+		}
+		go h.handle(conn)
+	}
+}
+
+func (h *HookServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev HookEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			log.Printf("bad hook event: %v", err)
+			continue
+		}
+
+		start := unixFloat(ev.Start)
+		entry := types.CommandEntry{
+			Timestamp: start,
+			Command:   ev.Command,
+			ExitCode:  ev.Exit,
+			Cwd:       ev.Cwd,
+		}
+		if ev.End >= ev.Start {
+			entry.Duration = unixFloat(ev.End).Sub(start)
+		}
+
+		h.mu.Lock()
+		h.pending = append(h.pending, entry)
+		h.active = true
+		h.mu.Unlock()
+	}
+}
+
+// ReportCommand delivers ev to every agent listening in HookSocketDir
+func ReportCommand(ev HookEvent) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	dir, err := HookSocketDir()
+	if err != nil {
+		return err
+	}
+	sockets, err := filepath.Glob(filepath.Join(dir, "hook-*.sock"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range sockets {
+		conn, err := net.DialTimeout("unix", path, 200*time.Millisecond)
+		if err != nil {
+			// no agent behind this socket any more
+			continue
+		}
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		conn.Write(line)
+		conn.Close()
+	}
+	return nil
+}
+
+func unixFloat(secs float64) time.Time {
+	whole := int64(secs)
+	return time.Unix(whole, int64((secs-float64(whole))*1e9))
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package lib
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode identifies the file behind a path so a rotated history file is noticed
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package lib
+
+import "os"
+
+// fileInode is unavailable from os.FileInfo on windows; rotation is still
+// caught by the tail check in resumeOffset
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// pwshCollector reads PSReadLine's ConsoleHost_history.txt
+type pwshCollector struct{}
+
+func init() { RegisterCollector(pwshCollector{}, "powershell") }
+
+func (pwshCollector) Name() string { return "pwsh" }
+
+func (pwshCollector) HistoryFile() (string, error) {
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return "", fmt.Errorf("APPDATA is not set")
+		}
+		return filepath.Join(appData, "Microsoft", "Windows", "PowerShell", "PSReadLine", "ConsoleHost_history.txt"), nil
+	}
+
+	dir, err := dataHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "powershell", "PSReadLine", "ConsoleHost_history.txt"), nil
+}
+
+// Parse joins multi-line commands, which PSReadLine writes with a trailing
+// backtick on every line but the last. The file has no timestamps.
+func (pwshCollector) Parse(lines []string, fallback time.Time) []types.CommandEntry {
+	var entries []types.CommandEntry
+
+	var parts []string
+	for _, line := range lines {
+		if line == "" && parts == nil {
+			continue
+		}
+		if cont, ok := strings.CutSuffix(line, "`"); ok {
+			parts = append(parts, cont)
+			continue
+		}
+
+		parts = append(parts, line)
+		entries = append(entries, types.CommandEntry{
+			Timestamp: fallback,
+			Command:   strings.Join(parts, "\n"),
+			ExitCode:  types.UnknownExitCode,
+		})
+		parts = nil
+	}
+
+	return entries
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the hooks background the report so a stopped agent never slows the prompt down
+
+// bashHook follows bash-preexec: DEBUG fires for every simple command, including
+// those PROMPT_COMMAND runs, so hits between __daemon_precmd and the
+// __daemon_prompt_done that ends PROMPT_COMMAND are ignored. An existing DEBUG
+// trap keeps running ahead of ours, as long as the snippet is eval'd: bash
+// hides DEBUG traps from sourced files.
+const bashHook = `# daemon shell integration (bash)
+__daemon_preexec() {
+  [ -z "$__daemon_in_prompt" ] || return
+  [ -n "$__daemon_ready" ] || return
+  case "$BASH_COMMAND" in __daemon_precmd*) return ;; esac
+  __daemon_ready=
+  __daemon_cmd=$(HISTTIMEFORMAT= builtin history 1 | sed 's/^ *[0-9]* *//')
+  __daemon_start=${EPOCHREALTIME:-$(date +%%s)}
+}
+__daemon_precmd() {
+  local ret=$?
+  __daemon_in_prompt=1
+  if [ -n "$__daemon_cmd" ]; then
+    (%[1]s hook-report -exit "$ret" -start "$__daemon_start" -end "${EPOCHREALTIME:-$(date +%%s)}" -cwd "$PWD" -- "$__daemon_cmd" >/dev/null 2>&1 &)
+  fi
+  __daemon_cmd=
+  return $ret
+}
+__daemon_prompt_done() {
+  __daemon_in_prompt=
+  __daemon_ready=1
+}
+__daemon_trap=$(trap -p DEBUG)
+case "$__daemon_trap" in
+  *__daemon_preexec*) ;;
+  ?*) __daemon_trap=${__daemon_trap#trap -- }; eval "__daemon_prev_debug=${__daemon_trap%% DEBUG}" ;;
+esac
+unset __daemon_trap
+trap 'eval "${__daemon_prev_debug:-:}"; __daemon_preexec' DEBUG
+case "$(declare -p PROMPT_COMMAND 2>/dev/null)" in
+  *__daemon_precmd*) ;;
+  "declare -a"*) PROMPT_COMMAND=(__daemon_precmd "${PROMPT_COMMAND[@]}" __daemon_prompt_done) ;;
+  *) PROMPT_COMMAND="__daemon_precmd${PROMPT_COMMAND:+;$PROMPT_COMMAND};__daemon_prompt_done" ;;
+esac
+`
+
+const zshHook = `# daemon shell integration (zsh)
+zmodload zsh/datetime
+autoload -Uz add-zsh-hook
+__daemon_preexec() {
+  __daemon_cmd="$1"
+  __daemon_start=$EPOCHREALTIME
+}
+__daemon_precmd() {
+  local ret=$?
+  if [[ -n $__daemon_cmd ]]; then
+    %[1]s hook-report -exit "$ret" -start "$__daemon_start" -end "$EPOCHREALTIME" -cwd "$PWD" -- "$__daemon_cmd" >/dev/null 2>&1 &!
+  fi
+  __daemon_cmd=
+}
+add-zsh-hook preexec __daemon_preexec
+add-zsh-hook precmd __daemon_precmd
+`
+
+const fishHook = `# daemon shell integration (fish)
+function __daemon_postexec --on-event fish_postexec
+    set -l ret $status
+    set -l end (date +%%s)
+    %[1]s hook-report -exit $ret -end $end -duration-ms $CMD_DURATION -cwd $PWD -- $argv[1] >/dev/null 2>&1 &
+    disown 2>/dev/null
+end
+`
+
+// ShellHook returns the snippet that wires shell into `exe hook-report`
+func ShellHook(shell, exe string) (string, error) {
+	var tmpl string
+	switch shell {
+	case "bash":
+		tmpl = bashHook
+	case "zsh":
+		tmpl = zshHook
+	case "fish":
+		tmpl = fishHook
+	default:
+		return "", fmt.Errorf("unsupported shell: %s (want bash, zsh or fish)", shell)
+	}
+
+	return fmt.Sprintf(tmpl, shellQuote(exe)), nil
+}
+
+// shellQuote single-quotes s for bash, zsh and fish
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package lib
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkPrivateDir accepts a real directory owned by this user with mode 0700
+func checkPrivateDir(info os.FileInfo) error {
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("cannot read owner")
+	}
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("owned by uid %d, not %d", st.Uid, os.Getuid())
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		return fmt.Errorf("mode %o, want 700", perm)
+	}
+	return nil
+}
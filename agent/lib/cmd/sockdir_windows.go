@@ -0,0 +1,16 @@
+//go:build windows
+
+package lib
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkPrivateDir accepts a real directory; the temp dir is already per user on windows
+func checkPrivateDir(info os.FileInfo) error {
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory")
+	}
+	return nil
+}
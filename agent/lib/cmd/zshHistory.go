@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// zshMeta is the byte zsh writes before a "metafied" byte; the byte that
+// follows has been xor-ed with 0x20. Non-ASCII text goes through this.
+const zshMeta = 0x83
+
+type zshCollector struct{}
+
+func init() { RegisterCollector(zshCollector{}) }
+
+func (zshCollector) Name() string { return "zsh" }
+
+func (zshCollector) HistoryFile() (string, error) { return homeFile(".zsh_history") }
+
+// Parse decodes extended history (": <start>:<elapsed>;<command>"). Plain
+// history lines without the header are stamped with fallback.
+func (zshCollector) Parse(lines []string, fallback time.Time) []types.CommandEntry {
+	var entries []types.CommandEntry
+
+	for _, record := range joinZshLines(lines) {
+		entry := types.CommandEntry{
+			Timestamp: fallback,
+			Command:   record,
+			ExitCode:  types.UnknownExitCode,
+		}
+
+		if start, elapsed, command, ok := parseZshHeader(record); ok {
+			entry.Timestamp = time.Unix(start, 0)
+			entry.Duration = time.Duration(elapsed) * time.Second
+			entry.Command = command
+		}
+
+		entry.Command = strings.TrimSpace(unmetafy(entry.Command))
+		if entry.Command == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// joinZshLines rebuilds multi-line commands. zsh writes every newline inside
+// a command as a backslash followed by the newline.
+func joinZshLines(lines []string) []string {
+	var records []string
+
+	var current []string
+	for _, line := range lines {
+		if cont, ok := strings.CutSuffix(line, `\`); ok {
+			current = append(current, cont)
+			continue
+		}
+
+		current = append(current, line)
+		records = append(records, strings.Join(current, "\n"))
+		current = nil
+	}
+	if current != nil {
+		records = append(records, strings.Join(current, "\n"))
+	}
+
+	return records
+}
+
+// parseZshHeader splits ": 1762008614:0;clear" into start, elapsed seconds and command
+func parseZshHeader(record string) (int64, int, string, bool) {
+	rest, ok := strings.CutPrefix(record, ": ")
+	if !ok {
+		return 0, 0, "", false
+	}
+
+	meta, command, ok := strings.Cut(rest, ";")
+	if !ok {
+		return 0, 0, "", false
+	}
+
+	startText, elapsedText, ok := strings.Cut(meta, ":")
+	if !ok {
+		return 0, 0, "", false
+	}
+
+	start, err := strconv.ParseInt(strings.TrimSpace(startText), 10, 64)
+	if err != nil {
+		return 0, 0, "", false
+	}
+
+	// zsh doesn't record the exit status, only how long the command ran
+	elapsed, err := strconv.Atoi(strings.TrimSpace(elapsedText))
+	if err != nil {
+		elapsed = 0
+	}
+
+	return start, elapsed, command, true
+}
+
+// unmetafy undoes zsh's metafication so non-ASCII commands come out as UTF-8
+func unmetafy(s string) string {
+	if strings.IndexByte(s, zshMeta) < 0 {
+		return s
+	}
+
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == zshMeta && i+1 < len(s) {
+			i++
+			b = append(b, s[i]^0x20)
+			continue
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJoinZshLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{"single", []string{": 1:0;ls"}, []string{": 1:0;ls"}},
+		{"two records", []string{": 1:0;ls", ": 2:0;pwd"}, []string{": 1:0;ls", ": 2:0;pwd"}},
+		{"for loop", []string{`: 1:0;for f in *; do\`, `  echo $f\`, "done"}, []string{": 1:0;for f in *; do\n  echo $f\ndone"}},
+		{"blank line in heredoc", []string{`: 1:0;cat <<EOF\`, `a\`, `\`, `b\`, "EOF"}, []string{": 1:0;cat <<EOF\na\n\nb\nEOF"}},
+		// the user's own continuation keeps its backslash, zsh escapes the newline after it
+		{"typed continuation", []string{`: 1:0;make \\`, "  all"}, []string{": 1:0;make \\\n  all"}},
+		{"torn last record", []string{`: 1:0;echo a\`}, []string{": 1:0;echo a"}},
+		{"plain history", []string{"ls", "pwd"}, []string{"ls", "pwd"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinZshLines(tt.lines); !slices.Equal(got, tt.want) {
+				t.Errorf("joinZshLines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmetafy(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"ascii", "echo hi", "echo hi"},
+		{"unmetafied utf-8", "café", "café"},
+		// 日 is e6 97 a5; 0x97 is metafied as 0x83 0xb7
+		{"metafied", "echo \xe6\x83\xb7\xa5", "echo 日"},
+		{"arrow", "\xe2\x83\xa6\x83\xb2", "→"},
+		{"meta at end", "abc\x83", "abc\x83"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unmetafy(tt.in); got != tt.want {
+				t.Errorf("unmetafy(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZshParseHistoryFile(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "zsh_history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	entries := zshCollector{}.Parse(lines, time.Time{})
+
+	want := []struct {
+		start   int64
+		elapsed time.Duration
+		command string
+	}{
+		{1700000000, 0, "ls -la"},
+		{1700000005, 0, "cat <<EOF > notes.txt\nfirst line\n\nthird line\nEOF"},
+		{1700000010, time.Second, "for f in *.go; do\n  gofmt -l $f\ndone"},
+		{1700000020, 42 * time.Second, "docker run --rm \\\n  -v $PWD:/src \\\n  golang:1.22 go test ./..."},
+		{1700000030, 0, "echo 日本語 → ok"},
+		{1700000031, 0, "git commit -m 'café crème'"},
+		{1700000040, 3 * time.Second, "make test"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Command != w.command || e.Timestamp.Unix() != w.start || e.Duration != w.elapsed {
+			t.Errorf("entry %d = %q at %d for %s, want %q at %d for %s",
+				i, e.Command, e.Timestamp.Unix(), e.Duration, w.command, w.start, w.elapsed)
+		}
+	}
+}
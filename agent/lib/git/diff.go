@@ -1,19 +1,39 @@
 package lib
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/utils/merkletrie"
 	"github.com/internal-hackathon-7/int-hack-7/agent/types"
 	"github.com/internal-hackathon-7/int-hack-7/agent/utils"
 )
 
-func DiffWithHash(projectPath, oldHash, newHash string) (types.DiffBlob, error) {
+// DefaultRenameThreshold is the similarity (0-100) a pair of files needs to count as a rename or copy
+const DefaultRenameThreshold = 50
+
+type DiffOptions struct {
+	// RenameThreshold is the minimum similarity percentage; 0 uses DefaultRenameThreshold
+	RenameThreshold int
+	// DaemonIgnore patterns are dropped from the diff even if an older snapshot has them
+	DaemonIgnore []string
+}
+
+// DiffWithHash diffs two snapshot trees with rename and copy detection
+func DiffWithHash(projectPath, oldHash, newHash string, opts DiffOptions) (types.DiffBlob, error) {
 	var diffBlob types.DiffBlob
 
+	threshold := opts.RenameThreshold
+	if threshold <= 0 || threshold > 100 {
+		threshold = DefaultRenameThreshold
+	}
+
 	// Open the repository
 	repo, err := FindRepo(projectPath)
 	if err != nil {
@@ -31,22 +51,18 @@ func DiffWithHash(projectPath, oldHash, newHash string) (types.DiffBlob, error)
 		return diffBlob, fmt.Errorf("new tree not found: %w", err)
 	}
 
-	// Compute changes between trees
-	changes, err := oldTree.Diff(newTree)
+	// Compute changes between trees, pairing deletes and inserts into renames
+	changes, err := object.DiffTreeWithOptions(context.Background(), oldTree, newTree, &object.DiffTreeOptions{
+		DetectRenames: true,
+		RenameScore:   uint(threshold),
+	})
 	if err != nil {
 		return diffBlob, fmt.Errorf("error generating diff: %w", err)
 	}
 
-	// Get the full patch diff (like `git diff-tree -p`)
-	// patch, err := changes.Patch()
-	// if err != nil {
-	// 	return diffBlob, fmt.Errorf("error creating patch: %w", err)
-	// }
-
-	// log.Println("\n--- PATCH DIFF ---")
-	// log.Println(patch.String())
+	changes = filterIgnored(changes, DaemonIgnoreMatcher(opts.DaemonIgnore))
 
-	diffBlob, err = BuildDiffJSON(projectPath, oldHash, newHash, changes)
+	diffBlob, err = BuildDiffJSON(repo, projectPath, oldTree, newTree, changes, threshold)
 	if err != nil {
 		return diffBlob, fmt.Errorf("error making change json : %w", err)
 	}
@@ -54,21 +70,47 @@ func DiffWithHash(projectPath, oldHash, newHash string) (types.DiffBlob, error)
 	return diffBlob, nil
 }
 
-func BuildDiffJSON(projectName, oldHash, newHash string, changes object.Changes) (types.DiffBlob, error) {
+func BuildDiffJSON(repo *git.Repository, projectName string, oldTree, newTree *object.Tree, changes object.Changes, threshold int) (types.DiffBlob, error) {
 	report := &types.DiffBlob{
 		ProjectName: projectName,
-		OldHash:     oldHash,
-		NewHash:     newHash,
+		OldHash:     oldTree.Hash.String(),
+		NewHash:     newTree.Hash.String(),
 		Timestamp:   time.Now().UTC().Format(time.RFC3339),
 	}
 
+	copies := newCopyDetector(repo, oldTree, changes, threshold)
+
 	var totalInsertions, totalDeletions, totalRenames, totalCopies int
 
 	for _, change := range changes {
 		action, _ := change.Action()
+		kind := strings.ToLower(action.String())
+		similarity := 0
+
+		// Count rename/copy summary stats
+		switch action {
+		case merkletrie.Insert:
+			// new file added, unless it was copied from an existing one
+			if src, score, ok := copies.source(change.To); ok {
+				change = &object.Change{From: src, To: change.To}
+				kind = "copy"
+				similarity = score
+				totalCopies++
+			}
+		case merkletrie.Delete:
+			// file deleted
+		case merkletrie.Modify:
+			// file modified, or moved when the rename detector paired two paths
+			if change.From.Name != change.To.Name {
+				kind = "rename"
+				similarity = copies.score(change.From.TreeEntry.Hash, change.To.TreeEntry.Hash)
+				totalRenames++
+			}
+		}
 
 		fileChange := types.FileChange{
-			Action: string(action),
+			Action:     kind,
+			Similarity: similarity,
 		}
 
 		if change.From.Name != "" {
@@ -100,16 +142,6 @@ func BuildDiffJSON(projectName, oldHash, newHash string, changes object.Changes)
 			}
 		}
 
-		// Count rename/copy summary stats
-		switch action {
-		case merkletrie.Insert:
-			// new file added
-		case merkletrie.Delete:
-			// file deleted
-		case merkletrie.Modify:
-			// file modified
-		}
-
 		report.Changes = append(report.Changes, fileChange)
 	}
 
@@ -123,3 +155,19 @@ func BuildDiffJSON(projectName, oldHash, newHash string, changes object.Changes)
 
 	return *report, nil
 }
+
+// filterIgnored drops changes whose old or new path is ignored
+func filterIgnored(changes object.Changes, ignore gitignore.Matcher) object.Changes {
+	if ignore == nil {
+		return changes
+	}
+
+	kept := changes[:0]
+	for _, c := range changes {
+		if matchesPath(ignore, c.From.Name) || matchesPath(ignore, c.To.Name) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
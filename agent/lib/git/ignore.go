@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// IgnoreMatcher combines the project's .gitignore files with the daemon_ignore
+// patterns from config; .git and .daemon are always ignored
+func IgnoreMatcher(projectPath string, daemonIgnore []string) (gitignore.Matcher, error) {
+	ps, err := gitignore.ReadPatterns(osfs.New(projectPath), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading .gitignore patterns: %w", err)
+	}
+
+	ps = append(ps,
+		gitignore.ParsePattern(".git/", nil),
+		gitignore.ParsePattern(".daemon/", nil),
+	)
+	for _, p := range daemonIgnore {
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+
+	return gitignore.NewMatcher(ps), nil
+}
+
+// DaemonIgnoreMatcher matches only the daemon_ignore patterns, or returns nil when there are none
+func DaemonIgnoreMatcher(daemonIgnore []string) gitignore.Matcher {
+	if len(daemonIgnore) == 0 {
+		return nil
+	}
+
+	ps := make([]gitignore.Pattern, 0, len(daemonIgnore))
+	for _, p := range daemonIgnore {
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+	return gitignore.NewMatcher(ps)
+}
+
+// matchesPath reports whether a slash-separated file path is ignored; a nil matcher ignores nothing
+func matchesPath(m gitignore.Matcher, name string) bool {
+	return m != nil && m.Match(strings.Split(name, "/"), false)
+}
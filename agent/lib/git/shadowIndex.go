@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/storage"
+)
+
+// shadowStorer shares the repo's object store and refs but keeps its own
+// staging area in .daemon/index, so snapshots never touch .git/index
+type shadowStorer struct {
+	storage.Storer
+	indexPath string
+}
+
+func (s *shadowStorer) Index() (*index.Index, error) {
+	f, err := os.Open(s.indexPath)
+	if os.IsNotExist(err) {
+		return &index.Index{Version: 2}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening shadow index: %w", err)
+	}
+	defer f.Close()
+
+	idx := &index.Index{}
+	if err := index.NewDecoder(bufio.NewReader(f)).Decode(idx); err != nil {
+		return nil, fmt.Errorf("error decoding shadow index: %w", err)
+	}
+	return idx, nil
+}
+
+func (s *shadowStorer) SetIndex(idx *index.Index) error {
+	if err := os.MkdirAll(filepath.Dir(s.indexPath), 0755); err != nil {
+		return fmt.Errorf("error creating shadow index dir: %w", err)
+	}
+
+	tmp := s.indexPath + ".lock"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening shadow index: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	if err := index.NewEncoder(w).Encode(idx); err != nil {
+		f.Close()
+		return fmt.Errorf("error encoding shadow index: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing shadow index: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing shadow index: %w", err)
+	}
+
+	return os.Rename(tmp, s.indexPath)
+}
+
+// OpenShadowRepo opens the project repo with its staging area redirected to .daemon/index
+func OpenShadowRepo(projectPath string) (*git.Repository, error) {
+	repo, err := FindRepo(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("error getting worktree: %w", err)
+	}
+
+	shadow := &shadowStorer{
+		Storer:    repo.Storer,
+		indexPath: filepath.Join(projectPath, ".daemon", "index"),
+	}
+
+	shadowRepo, err := git.Open(shadow, wt.Filesystem)
+	if err != nil {
+		return nil, fmt.Errorf("error opening shadow repo: %w", err)
+	}
+	return shadowRepo, nil
+}
@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotLeavesUserIndexAlone(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{
+		"app.go":   "line 1\nline 2\n",
+		"notes.md": "notes\n",
+	})
+	gitCmd(t, dir, "add", "-A")
+	gitCmd(t, dir, "commit", "-q", "-m", "initial")
+
+	// app.go is partially staged: the index holds one edit, the worktree two
+	writeFile(t, dir, "app.go", "line 1 staged\nline 2\n")
+	gitCmd(t, dir, "add", "app.go")
+	writeFile(t, dir, "app.go", "line 1 staged\nline 2 unstaged\n")
+	// a new file staged, and one left untracked
+	writeFile(t, dir, "new.go", "staged\n")
+	gitCmd(t, dir, "add", "new.go")
+	writeFile(t, dir, "untracked.txt", "untracked\n")
+
+	indexPath := filepath.Join(dir, ".git", "index")
+	before, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stagedBefore := gitCmd(t, dir, "diff", "--cached")
+
+	tree, err := CommitSnapshot(dir, "dev@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatal(".git/index changed during the snapshot")
+	}
+	if stagedAfter := gitCmd(t, dir, "diff", "--cached"); stagedAfter != stagedBefore {
+		t.Fatalf("staged diff changed:\n%s\nwant:\n%s", stagedAfter, stagedBefore)
+	}
+
+	// the snapshot itself holds the worktree, not the staged state
+	if got := gitCmd(t, dir, "cat-file", "-p", tree.String()+":app.go"); got != "line 1 staged\nline 2 unstaged" {
+		t.Errorf("snapshot app.go = %q", got)
+	}
+	if got := gitCmd(t, dir, "cat-file", "-p", tree.String()+":untracked.txt"); got != "untracked" {
+		t.Errorf("snapshot untracked.txt = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".daemon", "index")); err != nil {
+		t.Errorf("shadow index not written: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// maxSimilarityBlob caps the blob size read when scoring similarity
+const maxSimilarityBlob = 1 << 20
+
+// copyDetector finds the origin of newly added files, like `git diff -C`.
+// Exact copies may come from any file in the old tree; similar copies only
+// from files modified in the same change set, which keeps the search cheap.
+type copyDetector struct {
+	repo      *git.Repository
+	oldTree   *object.Tree
+	threshold int
+	// exact indexes the old tree by blob hash. Walking the whole tree costs
+	// as much as the repo is big, so it waits for the first added file.
+	exact    map[plumbing.Hash]object.ChangeEntry
+	modified []object.ChangeEntry
+	blobs    map[plumbing.Hash][]byte
+}
+
+// emptyBlob is the hash every empty file shares; that is not a copy
+var emptyBlob = plumbing.ComputeHash(plumbing.BlobObject, nil)
+
+func newCopyDetector(repo *git.Repository, oldTree *object.Tree, changes object.Changes, threshold int) *copyDetector {
+	d := &copyDetector{
+		repo:      repo,
+		oldTree:   oldTree,
+		threshold: threshold,
+		blobs:     map[plumbing.Hash][]byte{},
+	}
+
+	for _, c := range changes {
+		if action, _ := c.Action(); action == merkletrie.Modify {
+			d.modified = append(d.modified, c.From)
+		}
+	}
+
+	return d
+}
+
+// exactIndex builds exact from tree entries alone, without reading blobs
+func (d *copyDetector) exactIndex() map[plumbing.Hash]object.ChangeEntry {
+	if d.exact != nil {
+		return d.exact
+	}
+	d.exact = map[plumbing.Hash]object.ChangeEntry{}
+
+	walker := object.NewTreeWalker(d.oldTree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err != nil {
+			// io.EOF, or a tree we can't read; copies from it go undetected
+			break
+		}
+		if !entry.Mode.IsFile() || entry.Hash == emptyBlob {
+			continue
+		}
+		if _, ok := d.exact[entry.Hash]; !ok {
+			d.exact[entry.Hash] = object.ChangeEntry{Name: name, Tree: d.oldTree, TreeEntry: entry}
+		}
+	}
+	return d.exact
+}
+
+// source returns the best copy origin for an added file and its similarity score
+func (d *copyDetector) source(to object.ChangeEntry) (object.ChangeEntry, int, bool) {
+	if src, ok := d.exactIndex()[to.TreeEntry.Hash]; ok {
+		return src, 100, true
+	}
+
+	var best object.ChangeEntry
+	bestScore := 0
+	for _, src := range d.modified {
+		if score := d.score(src.TreeEntry.Hash, to.TreeEntry.Hash); score > bestScore {
+			best, bestScore = src, score
+		}
+	}
+
+	if bestScore < d.threshold {
+		return object.ChangeEntry{}, 0, false
+	}
+	return best, bestScore, true
+}
+
+// score is the percentage of bytes the two blobs share, matched line by line
+func (d *copyDetector) score(a, b plumbing.Hash) int {
+	if a == b {
+		return 100
+	}
+
+	x, okA := d.blob(a)
+	y, okB := d.blob(b)
+	if !okA || !okB {
+		return 0
+	}
+	return similarity(x, y)
+}
+
+func (d *copyDetector) blob(h plumbing.Hash) ([]byte, bool) {
+	if data, ok := d.blobs[h]; ok {
+		return data, data != nil
+	}
+
+	blob, err := d.repo.BlobObject(h)
+	if err != nil || blob.Size > maxSimilarityBlob {
+		d.blobs[h] = nil
+		return nil, false
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		d.blobs[h] = nil
+		return nil, false
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		d.blobs[h] = nil
+		return nil, false
+	}
+
+	d.blobs[h] = data
+	return data, true
+}
+
+func similarity(a, b []byte) int {
+	if len(a) == 0 && len(b) == 0 {
+		return 100
+	}
+
+	lines := map[string]int{}
+	for _, ln := range bytes.SplitAfter(a, []byte("\n")) {
+		lines[string(ln)]++
+	}
+
+	common := 0
+	for _, ln := range bytes.SplitAfter(b, []byte("\n")) {
+		if lines[string(ln)] > 0 {
+			lines[string(ln)]--
+			common += len(ln)
+		}
+	}
+
+	return common * 100 / max(len(a), len(b))
+}
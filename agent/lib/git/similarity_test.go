@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCopyDetectorIndexesOldTreeLazily(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{
+		"src/main.go": "package main\n\nfunc main() {}\n",
+		"empty.txt":   "",
+	})
+	gitCmd(t, dir, "add", "-A")
+	oldHash := gitCmd(t, dir, "write-tree")
+
+	writeFile(t, dir, "copy/main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, dir, "new-empty.txt", "")
+	gitCmd(t, dir, "add", "-A")
+	newHash := gitCmd(t, dir, "write-tree")
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldTree, err := repo.TreeObject(plumbing.NewHash(oldHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTree, err := repo.TreeObject(plumbing.NewHash(newHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := object.DiffTree(oldTree, newTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := newCopyDetector(repo, oldTree, changes, 50)
+	if d.exact != nil {
+		t.Fatal("old tree indexed before any added file was looked up")
+	}
+
+	for _, c := range changes {
+		src, score, ok := d.source(c.To)
+		switch c.To.Name {
+		case "copy/main.go":
+			if !ok || src.Name != "src/main.go" || score != 100 {
+				t.Errorf("copy/main.go: source %q score %d ok %v", src.Name, score, ok)
+			}
+		case "new-empty.txt":
+			if ok {
+				t.Errorf("empty file reported as a copy of %q", src.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// SnapshotRefName is the private ref holding a member's snapshot chain
+func SnapshotRefName(email string) plumbing.ReferenceName {
+	if email == "" {
+		email = "local"
+	}
+	return plumbing.ReferenceName(fmt.Sprintf("refs/daemon/%s/snapshots", email))
+}
+
+// LastSnapshotTree returns the tree of the newest snapshot, or ZeroHash if there is none yet
+func LastSnapshotTree(projectPath, email string) (plumbing.Hash, error) {
+	repo, err := FindRepo(projectPath)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref, err := repo.Reference(SnapshotRefName(email), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error reading snapshot ref: %w", err)
+	}
+
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error reading snapshot commit: %w", err)
+	}
+
+	return commit.TreeHash, nil
+}
+
+// MigrateStateFile imports the tree hashes of a legacy .daemon/state.txt as a
+// snapshot chain, then renames the file so the import only ever runs once.
+// The old agent wrote flat trees, so each one is rebuilt nested before it is
+// imported. When nothing can be imported the ref is left unset and the first
+// snapshot starts a fresh chain.
+func MigrateStateFile(projectPath, email string) error {
+	stateFile := filepath.Join(projectPath, ".daemon", "state.txt")
+
+	f, err := os.Open(stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening state file: %w", err)
+	}
+	defer f.Close()
+
+	repo, err := FindRepo(projectPath)
+	if err != nil {
+		return err
+	}
+
+	refName := SnapshotRefName(email)
+	if _, err := repo.Reference(refName, true); err == nil {
+		log.Printf("%s already exists, not importing %s", refName, stateFile)
+		return os.Rename(stateFile, stateFile+".migrated")
+	}
+
+	var parent plumbing.Hash
+	imported := 0
+
+	// each line is `<RFC3339 time> <tree hash>`
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 2 {
+			continue
+		}
+
+		when, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			continue
+		}
+
+		treeHash, err := nestTree(repo, plumbing.NewHash(parts[len(parts)-1]))
+		if err != nil {
+			log.Printf("skipping state.txt entry %s: %v", parts[len(parts)-1], err)
+			continue
+		}
+
+		var parents []plumbing.Hash
+		if !parent.IsZero() {
+			parents = append(parents, parent)
+		}
+
+		parent, err = storeSnapshotCommit(repo.Storer, treeHash, parents, when)
+		if err != nil {
+			return err
+		}
+		imported++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading state file: %w", err)
+	}
+
+	if !parent.IsZero() {
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(refName, parent)); err != nil {
+			return fmt.Errorf("error updating %s: %w", refName, err)
+		}
+	}
+
+	f.Close()
+	log.Printf("imported %d snapshots from %s into %s", imported, stateFile, refName)
+	return os.Rename(stateFile, stateFile+".migrated")
+}
+
+// nestTree rewrites a tree whose entry names are whole paths, like the old
+// agent's "src/main.go", as one tree per directory the way git writes them.
+// Trees that are already nested come out unchanged.
+func nestTree(repo *git.Repository, treeHash plumbing.Hash) (plumbing.Hash, error) {
+	idx := &index.Index{Version: 2}
+	if err := flattenTree(repo, treeHash, "", idx); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return buildTree(repo.Storer, idx, nil)
+}
+
+// flattenTree adds every non-directory entry under treeHash to idx by full path
+func flattenTree(repo *git.Repository, treeHash plumbing.Hash, prefix string, idx *index.Index) error {
+	tree, err := repo.TreeObject(treeHash)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range tree.Entries {
+		name := prefix + e.Name
+		if e.Mode == filemode.Dir {
+			if err := flattenTree(repo, e.Hash, name+"/", idx); err != nil {
+				return err
+			}
+			continue
+		}
+		idx.Entries = append(idx.Entries, &index.Entry{Name: name, Mode: e.Mode, Hash: e.Hash})
+	}
+	return nil
+}
+
+func storeSnapshotCommit(s storer.EncodedObjectStorer, treeHash plumbing.Hash, parents []plumbing.Hash, when time.Time) (plumbing.Hash, error) {
+	sig := object.Signature{
+		Name:  "Daemon Auto Commit",
+		Email: "daemon@local",
+		When:  when,
+	}
+
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      fmt.Sprintf("Snapshot %s", when.Format("2006-01-02 15:04")),
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	obj := s.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error encoding commit: %w", err)
+	}
+
+	commitHash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error storing commit: %w", err)
+	}
+	return commitHash, nil
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestMigrateStateFileNestsFlatTrees(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{
+		"README.md":        "hello\n",
+		"src/main.go":      "package main\n",
+		"src/lib/util.go":  "package lib\n",
+		"docs/guide/a.txt": "a\n",
+	})
+	gitCmd(t, dir, "add", "-A")
+	want := gitCmd(t, dir, "write-tree")
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old agent wrote one tree holding every file by full path
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat := &object.Tree{}
+	for _, e := range idx.Entries {
+		flat.Entries = append(flat.Entries, object.TreeEntry{Name: e.Name, Mode: filemode.Regular, Hash: e.Hash})
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := flat.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	flatHash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := "2025-01-02T10:00:00Z " + flatHash.String() + "\n" +
+		"2025-01-02T10:05:00Z " + plumbing.NewHash("1111111111111111111111111111111111111111").String() + "\n"
+	writeFile(t, dir, ".daemon/state.txt", state)
+
+	if err := MigrateStateFile(dir, "dev@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LastSnapshotTree(dir, "dev@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want {
+		t.Fatalf("imported tree = %s, want the nested %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".daemon", "state.txt.migrated")); err != nil {
+		t.Errorf("state.txt not renamed: %v", err)
+	}
+}
+
+func TestMigrateStateFileWithNothingToImport(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{"a.txt": "a\n"})
+	writeFile(t, dir, ".daemon/state.txt", "2025-01-02T10:00:00Z 1111111111111111111111111111111111111111\n")
+
+	if err := MigrateStateFile(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// no ref: the first snapshot starts the chain
+	if got, err := LastSnapshotTree(dir, ""); err != nil || !got.IsZero() {
+		t.Fatalf("LastSnapshotTree = %s, %v; want none", got, err)
+	}
+}
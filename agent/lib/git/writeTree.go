@@ -3,17 +3,25 @@ package lib
 import (
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 )
 
-func CommitSnapshot(projectPath string) (plumbing.Hash, error) {
-	repo, err := FindRepo(projectPath)
+// CommitSnapshot stages the worktree into the shadow index, writes its tree and
+// records it as a commit on the member's snapshot ref. It returns the tree hash.
+// Paths matching daemonIgnore never enter the snapshot.
+func CommitSnapshot(projectPath, email string, daemonIgnore []string) (plumbing.Hash, error) {
+	// stage into .daemon/index so the user's own staging area is left alone
+	repo, err := OpenShadowRepo(projectPath)
 	if err != nil {
 		return plumbing.ZeroHash, fmt.Errorf("error opening repo: %w", err)
 	}
@@ -23,108 +31,151 @@ func CommitSnapshot(projectPath string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, fmt.Errorf("error getting worktree: %w", err)
 	}
 
-	// Stage everything like `git add .`
+	// the agent's own state must never be snapshotted, even without a .gitignore entry
+	daemonIgnore = append([]string{".daemon/"}, daemonIgnore...)
+	ignore := DaemonIgnoreMatcher(daemonIgnore)
+	for _, p := range daemonIgnore {
+		wt.Excludes = append(wt.Excludes, gitignore.ParsePattern(p, nil))
+	}
+
+	// Stage everything like `git add .`, into the shadow index
 	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
 		return plumbing.ZeroHash, fmt.Errorf("error adding files: %w", err)
 	}
 
 	// Create a tree from the index
-	treeHash, err := WriteTree(projectPath, repo)
+	treeHash, err := WriteTree(repo, ignore)
 	if err != nil {
 		return plumbing.ZeroHash, fmt.Errorf("error writing tree: %w", err)
 	}
 
-	// Optional: get parent commit (HEAD)
+	// Parent on the previous snapshot, not HEAD
+	refName := SnapshotRefName(email)
 	var parents []plumbing.Hash
-	if head, err := repo.Head(); err == nil {
-		if parentCommit, err := repo.CommitObject(head.Hash()); err == nil {
+	if ref, err := repo.Reference(refName, true); err == nil {
+		if parentCommit, err := repo.CommitObject(ref.Hash()); err == nil {
+			if parentCommit.TreeHash == treeHash {
+				// nothing changed, keep the chain free of empty snapshots
+				return treeHash, nil
+			}
 			parents = append(parents, parentCommit.Hash)
 		}
 	}
 
-	// Create a commit object (but not update HEAD)
-	commit := &object.Commit{
-		Author: object.Signature{
-			Name:  "Daemon Auto Commit",
-			Email: "daemon@local",
-			When:  time.Now(),
-		},
-		Committer: object.Signature{
-			Name:  "Daemon Auto Commit",
-			Email: "daemon@local",
-			When:  time.Now(),
-		},
-		Message:      fmt.Sprintf("Snapshot %s", time.Now().Format("2006-01-02 15:04")),
-		TreeHash:     treeHash,
-		ParentHashes: parents,
+	commitHash, err := storeSnapshotCommit(repo.Storer, treeHash, parents, time.Now())
+	if err != nil {
+		return plumbing.ZeroHash, err
 	}
 
-	obj := repo.Storer.NewEncodedObject()
-	if err := commit.Encode(obj); err != nil {
-		return plumbing.ZeroHash, fmt.Errorf("error encoding commit: %w", err)
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(refName, commitHash)); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error updating %s: %w", refName, err)
 	}
-	// commitHash, err := repo.Storer.SetEncodedObject(obj)
-	// if err != nil {
-	// 	return plumbing.ZeroHash, fmt.Errorf("error storing commit: %w", err)
-	// }
 
-	//IMPORTANT : for now we make the commits but are not using it, so only returning the tree hash... some of above lines are future oriented.
+	log.Printf("new snapshot %s (tree %s) on %s", commitHash, treeHash, refName)
 
 	return treeHash, nil
 }
 
-func WriteTree(projectPath string, repo *git.Repository) (plumbing.Hash, error) {
-	// 1️⃣ Get the index (the staging area)
+// WriteTree writes the index as a tree, leaving out entries matched by ignore.
+// Files staged before a pattern was added are dropped here too.
+func WriteTree(repo *git.Repository, ignore gitignore.Matcher) (plumbing.Hash, error) {
+	// 1️⃣ Get the index (the staging area; the shadow index for snapshot repos)
 	idx, err := repo.Storer.Index()
 	if err != nil {
 		return plumbing.ZeroHash, fmt.Errorf("cannot read index: %w", err)
 	}
 
-	// 2️⃣ Convert the index to a Tree object
-	tree := &object.Tree{}
+	// 2️⃣ Build the nested trees bottom-up and store them
+	treeHash, err := buildTree(repo.Storer, idx, ignore)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return treeHash, nil
+}
+
+// treeNode is one directory of the index while building trees
+type treeNode struct {
+	files    []object.TreeEntry
+	children map[string]*treeNode
+}
+
+func newTreeNode() *treeNode {
+	return &treeNode{children: map[string]*treeNode{}}
+}
+
+// buildTree groups index entries by directory and writes one tree object per
+// directory, children first, returning the root tree hash like `git write-tree`
+func buildTree(s storer.EncodedObjectStorer, idx *index.Index, ignore gitignore.Matcher) (plumbing.Hash, error) {
+	root := newTreeNode()
+
 	for _, entry := range idx.Entries {
-		tree.Entries = append(tree.Entries, object.TreeEntry{
-			Name: entry.Name,
+		// unmerged entries (stages 1-3) can't be written to a tree; note go-git's
+		// index.Merged constant is 1, but merged entries decode as stage 0
+		if entry.Stage != 0 {
+			continue
+		}
+
+		if matchesPath(ignore, entry.Name) {
+			continue
+		}
+
+		node := root
+		parts := strings.Split(entry.Name, "/")
+		for _, dir := range parts[:len(parts)-1] {
+			child, ok := node.children[dir]
+			if !ok {
+				child = newTreeNode()
+				node.children[dir] = child
+			}
+			node = child
+		}
+
+		node.files = append(node.files, object.TreeEntry{
+			Name: parts[len(parts)-1],
 			Mode: entry.Mode,
 			Hash: entry.Hash,
 		})
 	}
 
-	// 3️⃣ Encode and store the Tree object
-	obj := repo.Storer.NewEncodedObject()
-	if err := tree.Encode(obj); err != nil {
-		return plumbing.ZeroHash, fmt.Errorf("error encoding tree: %w", err)
-	}
+	return writeTreeNode(s, root)
+}
 
-	// 4️⃣ Write the object to the database
-	treeHash, err := repo.Storer.SetEncodedObject(obj)
-	if err != nil {
-		return plumbing.ZeroHash, fmt.Errorf("error saving tree: %w", err)
+func writeTreeNode(s storer.EncodedObjectStorer, node *treeNode) (plumbing.Hash, error) {
+	tree := &object.Tree{Entries: node.files}
+
+	for name, child := range node.children {
+		hash, err := writeTreeNode(s, child)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{
+			Name: name,
+			Mode: filemode.Dir,
+			Hash: hash,
+		})
 	}
 
-	// 5️⃣ Store the generated tree hash in .daemon/state.txt
-	stateDir := filepath.Join(projectPath, ".daemon")
-	stateFile := filepath.Join(stateDir, "state.txt")
+	// git orders entries as if directory names had a trailing slash
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return treeSortName(tree.Entries[i]) < treeSortName(tree.Entries[j])
+	})
 
-	// Ensure the directory exists
-	if err := os.MkdirAll(stateDir, 0755); err != nil {
-		return plumbing.ZeroHash, fmt.Errorf("error creating state dir: %w", err)
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("error encoding tree: %w", err)
 	}
 
-	// Open (or create) the file in append mode
-	f, err := os.OpenFile(stateFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	hash, err := s.SetEncodedObject(obj)
 	if err != nil {
-		return plumbing.ZeroHash, fmt.Errorf("error opening state file: %w", err)
+		return plumbing.ZeroHash, fmt.Errorf("error saving tree: %w", err)
 	}
-	defer f.Close()
+	return hash, nil
+}
 
-	// Write a line with timestamp and hash
-	line := fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), treeHash.String())
-	if _, err := f.WriteString(line); err != nil {
-		return plumbing.ZeroHash, fmt.Errorf("error writing to state file: %w", err)
+func treeSortName(e object.TreeEntry) string {
+	if e.Mode == filemode.Dir {
+		return e.Name + "/"
 	}
-
-	log.Printf("new hash added : %s", treeHash.String())
-
-	return treeHash, nil
+	return e.Name
 }
@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// gitCmd runs git in dir and returns its trimmed output
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newTestRepo creates a repo in a temp dir with files, mapping path to content
+func newTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "-q")
+	for name, content := range files {
+		writeFile(t, dir, name, content)
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildTreeMatchesGitWriteTree(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{
+		"README.md":             "hello\n",
+		"src/main.go":           "package main\n",
+		"src/lib/util/util.go":  "package util\n",
+		"src/lib/util.go":       "package lib\n",
+		"src-b/other.go":        "package b\n",
+		"src.txt":               "not a dir\n",
+		"a/b/c/d/deep.txt":      "deep\n",
+		"scripts/build.sh":      "#!/bin/sh\n",
+		"docs/guide/index.html": "<html></html>\n",
+	})
+	if err := os.Chmod(filepath.Join(dir, "scripts/build.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("src/main.go", filepath.Join(dir, "main-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	gitCmd(t, dir, "add", "-A")
+	want := gitCmd(t, dir, "write-tree")
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := WriteTree(repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want {
+		t.Fatalf("tree = %s, git write-tree = %s", got, want)
+	}
+
+	// git must be able to read back every tree we stored
+	gitCmd(t, dir, "ls-tree", "-r", got.String())
+}
+
+func TestBuildTreeSkipsIgnored(t *testing.T) {
+	dir := newTestRepo(t, map[string]string{
+		"keep.txt":       "keep\n",
+		"secret/key.pem": "-----BEGIN-----\n",
+	})
+	gitCmd(t, dir, "add", "-A")
+
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := WriteTree(repo, DaemonIgnoreMatcher([]string{"secret/"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := gitCmd(t, dir, "ls-tree", "-r", "--name-only", got.String()); names != "keep.txt" {
+		t.Fatalf("tree holds %q, want only keep.txt", names)
+	}
+}
@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/constants"
+)
+
+// Record kinds understood by Deliver, as stored in the outbox
+const (
+	KindDiffBlob    = "diff_blob"
+	KindCmdDiffBlob = "cmd_diff_blob"
+)
+
+var endpoints = map[string]string{
+	KindDiffBlob:    "/daemon/addDiffBlobs",
+	KindCmdDiffBlob: "/daemon/addCmdDiffBlobs",
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// StatusError is returned when the master answers with a non-2xx status
+type StatusError struct {
+	Code    int
+	Status  string
+	Message string
+	// Reason is the master's machine-readable `code` field, when it sends one
+	Reason string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("master responded with %s: %s", e.Status, e.Message)
+}
+
+// Permanent reports whether retrying the same request can never succeed
+func (e *StatusError) Permanent() bool {
+	return e.Code >= 400 && e.Code < 500 &&
+		e.Code != http.StatusRequestTimeout && e.Code != http.StatusTooManyRequests
+}
+
+// Deliver posts an already-encoded wire body to the endpoint for its kind
+func Deliver(kind string, body []byte) error {
+	path, ok := endpoints[kind]
+	if !ok {
+		return &StatusError{Code: http.StatusBadRequest, Status: "unknown kind", Message: kind}
+	}
+
+	if err := postRaw(path, body, nil); err != nil {
+		return fmt.Errorf("error uploading %s: %w", kind, err)
+	}
+	return nil
+}
+
+// masterURL overrides constants.MasterURL once the agent's config is loaded;
+// it can change under in-flight uploads when the config is reloaded
+var masterURL atomic.Value
+
+// SetMasterURL points every later request at url
+func SetMasterURL(url string) {
+	masterURL.Store(url)
+}
+
+func baseURL() string {
+	if url, ok := masterURL.Load().(string); ok && url != "" {
+		return url
+	}
+	return constants.MasterURL
+}
+
+// postJSON sends body to the master and treats any non-2xx status as an error
+func postJSON(path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error encoding request: %w", err)
+	}
+	return postRaw(path, data, out)
+}
+
+// postRaw sends an encoded body and, when out is non-nil, decodes a 2xx response into it
+func postRaw(path string, data []byte, out any) error {
+	resp, err := httpClient.Post(baseURL()+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error reaching master: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, reason := readError(resp.Body)
+		return &StatusError{Code: resp.StatusCode, Status: resp.Status, Message: message, Reason: reason}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("error decoding master response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// readError pulls the `error` and `code` fields out of a master error response, falling back to the raw body
+func readError(r io.Reader) (message, reason string) {
+	raw, _ := io.ReadAll(io.LimitReader(r, 4096))
+
+	var payload struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	}
+	if err := json.Unmarshal(raw, &payload); err == nil {
+		if payload.Error != "" {
+			return payload.Error, payload.Code
+		}
+		if payload.Message != "" {
+			return payload.Message, payload.Code
+		}
+	}
+	return string(bytes.TrimSpace(raw)), ""
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMemberNotFound = errors.New("no member registered with this email")
+	ErrRoomNotFound   = errors.New("room not found")
+)
+
+type joinRoomRequest struct {
+	RoomID string `json:"roomId"`
+	Gmail  string `json:"gmail"`
+}
+
+type joinRoomResponse struct {
+	Message  string `json:"message"`
+	MemberID string `json:"memberId"`
+	Interval struct {
+		MinSeconds     int `json:"minSeconds"`
+		DefaultSeconds int `json:"defaultSeconds"`
+		MaxSeconds     int `json:"maxSeconds"`
+	} `json:"interval"`
+}
+
+// RoomInterval is how often the room lets its agents tick. Zero fields were
+// not set by the master.
+type RoomInterval struct {
+	Min     time.Duration
+	Default time.Duration
+	Max     time.Duration
+}
+
+// JoinRoom adds the member with this email to the room and returns their
+// googleId and the room's interval bounds. Joining again is harmless.
+func JoinRoom(roomID, email string) (string, RoomInterval, error) {
+	var resp joinRoomResponse
+
+	err := postJSON("/daemon/joinRoom", joinRoomRequest{RoomID: roomID, Gmail: email}, &resp)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+			switch {
+			case statusErr.Reason == "MEMBER_NOT_FOUND" || strings.HasPrefix(statusErr.Message, "Member not found"):
+				return "", RoomInterval{}, fmt.Errorf("%w: %s", ErrMemberNotFound, email)
+			case statusErr.Reason == "ROOM_NOT_FOUND" || strings.HasPrefix(statusErr.Message, "Room not found"):
+				return "", RoomInterval{}, fmt.Errorf("%w: %s", ErrRoomNotFound, roomID)
+			}
+		}
+		return "", RoomInterval{}, fmt.Errorf("error joining room: %w", err)
+	}
+
+	if resp.MemberID == "" {
+		return "", RoomInterval{}, errors.New("master did not return a memberId")
+	}
+
+	interval := RoomInterval{
+		Min:     time.Duration(resp.Interval.MinSeconds) * time.Second,
+		Default: time.Duration(resp.Interval.DefaultSeconds) * time.Second,
+		Max:     time.Duration(resp.Interval.MaxSeconds) * time.Second,
+	}
+	return resp.MemberID, interval, nil
+}
@@ -0,0 +1,419 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	recordsFile = "records.log"
+	cursorFile  = "cursor"
+
+	minBackoff = 2 * time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Record is one queued upload. Body is the exact JSON sent to the master.
+type Record struct {
+	Kind string
+	Body []byte
+}
+
+// Outbox is an append-only spool under .daemon/outbox/.
+//
+// Each record is a single line `<crc32> <kind> <json>`; json.Marshal never emits raw
+// newlines so a line is always a whole record. The cursor file holds the byte offset of
+// the oldest undelivered record and is replaced atomically after every delivery, so the
+// queue survives agent restarts. Once everything is delivered the log is truncated.
+type Outbox struct {
+	dir    string
+	mu     sync.Mutex
+	notify chan struct{}
+	kick   chan struct{}
+}
+
+// Open opens the project's own outbox
+func Open(projectPath string) (*Outbox, error) {
+	return OpenDir(projectOutboxDir(projectPath))
+}
+
+// OpenDir opens an outbox kept in dir, e.g. one shared by several projects
+func OpenDir(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox dir: %w", err)
+	}
+
+	o := &Outbox{dir: dir, notify: make(chan struct{}, 1), kick: make(chan struct{}, 1)}
+	if err := o.repair(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Dir is where the outbox keeps its files
+func (o *Outbox) Dir() string {
+	return o.dir
+}
+
+// Append marshals payload and durably adds it to the end of the queue
+func (o *Outbox) Append(kind string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox record: %w", err)
+	}
+
+	line := encodeRecord(kind, body)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	f, err := os.OpenFile(o.path(recordsFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("failed to append to outbox: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox: %w", err)
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Kick cuts short Run's idle wait or retry backoff so queued records go out now
+func (o *Outbox) Kick() {
+	select {
+	case o.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Depth counts the records still waiting to be delivered
+func (o *Outbox) Depth() (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r, closeFn, err := o.openAtCursor()
+	if err != nil || r == nil {
+		return 0, err
+	}
+	defer closeFn()
+
+	depth := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			depth++
+		}
+		if err != nil {
+			break
+		}
+	}
+	return depth, nil
+}
+
+// Run drains the outbox in order until ctx is done, calling send for each record.
+// Failed sends are retried with exponential backoff and jitter; records whose
+// error reports Permanent() are dropped so one bad payload cannot wedge the queue.
+func (o *Outbox) Run(ctx context.Context, send func(Record) error) {
+	backoff := minBackoff
+
+	for ctx.Err() == nil {
+		delivered, empty, err := o.deliverNext(send)
+		switch {
+		case err != nil:
+			delay := jitter(backoff)
+			log.Printf("outbox delivery FAILED, retrying in %s: %v\n", delay.Round(time.Second), err)
+			o.sleep(ctx, delay)
+			backoff = min(backoff*2, maxBackoff)
+		case empty:
+			o.wait(ctx, maxBackoff)
+		case delivered:
+			backoff = minBackoff
+		}
+	}
+}
+
+// Flush delivers queued records until the outbox is empty, a send fails or
+// ctx is done. Whatever is left stays on disk for the next start.
+func (o *Outbox) Flush(ctx context.Context, send func(Record) error) error {
+	for ctx.Err() == nil {
+		_, empty, err := o.deliverNext(send)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// deliverNext sends the oldest record and acks it on success or permanent failure
+func (o *Outbox) deliverNext(send func(Record) error) (delivered, empty bool, err error) {
+	rec, next, ok, err := o.peek()
+	if err != nil {
+		return false, false, fmt.Errorf("outbox read error: %w", err)
+	}
+	if !ok {
+		return false, true, nil
+	}
+
+	if err := send(rec); err != nil {
+		if !isPermanent(err) {
+			return false, false, fmt.Errorf("%s: %w", rec.Kind, err)
+		}
+		log.Printf("outbox dropping undeliverable %s record: %v\n", rec.Kind, err)
+	} else {
+		log.Printf("outbox delivered %s record\n", rec.Kind)
+	}
+
+	if err := o.ack(next); err != nil {
+		log.Printf("outbox ack error: %v\n", err)
+	}
+	return true, false, nil
+}
+
+// peek returns the oldest valid record and the offset just past it.
+// Records that fail their checksum are skipped over and logged.
+func (o *Outbox) peek() (Record, int64, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	cursor, err := o.readCursor()
+	if err != nil {
+		return Record{}, 0, false, err
+	}
+
+	r, closeFn, err := o.openAtCursor()
+	if err != nil || r == nil {
+		return Record{}, 0, false, err
+	}
+	defer closeFn()
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return Record{}, 0, false, nil
+		}
+		if err != nil {
+			return Record{}, 0, false, err
+		}
+		cursor += int64(len(line))
+
+		rec, err := decodeRecord(line)
+		if err != nil {
+			log.Printf("outbox skipping corrupt record: %v\n", err)
+			if err := o.writeCursor(cursor); err != nil {
+				return Record{}, 0, false, err
+			}
+			continue
+		}
+		return rec, cursor, true, nil
+	}
+}
+
+// ack marks everything before next as delivered, compacting the log once it is drained
+func (o *Outbox) ack(next int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	info, err := os.Stat(o.path(recordsFile))
+	if err != nil {
+		return err
+	}
+
+	if next < info.Size() {
+		return o.writeCursor(next)
+	}
+
+	// reset the cursor first: a crash before the truncate redelivers the
+	// drained log, where the other order would skip records appended later
+	if err := o.writeCursor(0); err != nil {
+		return err
+	}
+	return os.Truncate(o.path(recordsFile), 0)
+}
+
+// repair drops a torn trailing write left behind by a crash mid-append, and
+// rewinds a cursor left past the end of the log
+func (o *Outbox) repair() error {
+	data, err := os.ReadFile(o.path(recordsFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		keep := bytes.LastIndexByte(data, '\n') + 1
+		log.Printf("outbox truncating %d bytes of torn record\n", len(data)-keep)
+		if err := os.Truncate(o.path(recordsFile), int64(keep)); err != nil {
+			return err
+		}
+		data = data[:keep]
+	}
+
+	return o.repairCursor(int64(len(data)))
+}
+
+// repairCursor resets a cursor beyond the log's size to 0. Left alone, new
+// records would land before it and never be read.
+func (o *Outbox) repairCursor(size int64) error {
+	cursor, err := o.readCursor()
+	if err != nil {
+		return err
+	}
+	if cursor <= size {
+		return nil
+	}
+	log.Printf("outbox cursor %d is past the end of the log (%d bytes), rewinding\n", cursor, size)
+	return o.writeCursor(0)
+}
+
+// openAtCursor returns a reader positioned at the cursor, or nil if there is no log yet
+func (o *Outbox) openAtCursor() (*bufio.Reader, func(), error) {
+	cursor, err := o.readCursor()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(o.path(recordsFile))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open outbox: %w", err)
+	}
+
+	if _, err := f.Seek(cursor, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to seek outbox: %w", err)
+	}
+	return bufio.NewReader(f), func() { f.Close() }, nil
+}
+
+func (o *Outbox) readCursor() (int64, error) {
+	data, err := os.ReadFile(o.path(cursorFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read outbox cursor: %w", err)
+	}
+
+	cursor, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid outbox cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+func (o *Outbox) writeCursor(cursor int64) error {
+	tmp := o.path(cursorFile + ".tmp")
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(cursor, 10)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write outbox cursor: %w", err)
+	}
+	return os.Rename(tmp, o.path(cursorFile))
+}
+
+// wait returns after d, when a record is appended, on Kick, or when ctx is done
+func (o *Outbox) wait(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-o.notify:
+	case <-o.kick:
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// sleep waits for d unless Kick is called or ctx is done first
+func (o *Outbox) sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-o.kick:
+	case <-ctx.Done():
+	}
+}
+
+func (o *Outbox) path(name string) string {
+	return filepath.Join(o.dir, name)
+}
+
+func encodeRecord(kind string, body []byte) []byte {
+	payload := append([]byte(kind+" "), body...)
+	return fmt.Appendf(nil, "%08x %s\n", crc32.ChecksumIEEE(payload), payload)
+}
+
+func decodeRecord(line []byte) (Record, error) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+
+	sum, payload, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		return Record{}, errors.New("missing checksum")
+	}
+
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil {
+		return Record{}, fmt.Errorf("bad checksum field: %w", err)
+	}
+	if crc32.ChecksumIEEE(payload) != uint32(want) {
+		return Record{}, errors.New("checksum mismatch")
+	}
+
+	kind, body, ok := bytes.Cut(payload, []byte(" "))
+	if !ok {
+		return Record{}, errors.New("missing kind")
+	}
+	return Record{Kind: string(kind), Body: body}, nil
+}
+
+func isPermanent(err error) bool {
+	var p interface{ Permanent() bool }
+	return errors.As(err, &p) && p.Permanent()
+}
+
+// jitter spreads retries across [d/2, d) so agents don't reconnect in lockstep
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + rand.N(half)
+}
+
+// PendingDepth counts undelivered records without opening the outbox for
+// writing, so another process can inspect a running agent's queue
+func PendingDepth(projectPath string) (int, error) {
+	return PendingDepthDir(projectOutboxDir(projectPath))
+}
+
+// PendingDepthDir is PendingDepth for an outbox opened with OpenDir
+func PendingDepthDir(dir string) (int, error) {
+	o := &Outbox{dir: dir}
+	return o.Depth()
+}
+
+func projectOutboxDir(projectPath string) string {
+	return filepath.Join(projectPath, ".daemon", "outbox")
+}
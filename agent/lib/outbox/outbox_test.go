@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlushDeliversInOrderAndCompacts(t *testing.T) {
+	o, err := OpenDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []string{"a", "b", "c"} {
+		if err := o.Append(kind, map[string]string{"kind": kind}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	err = o.Flush(context.Background(), func(r Record) error {
+		got = append(got, r.Kind)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != "a" || got[2] != "c" {
+		t.Fatalf("delivered %v", got)
+	}
+
+	info, err := os.Stat(filepath.Join(o.Dir(), recordsFile))
+	if err != nil || info.Size() != 0 {
+		t.Fatalf("log not compacted: %v %v", info, err)
+	}
+	if cursor, err := o.readCursor(); err != nil || cursor != 0 {
+		t.Fatalf("cursor = %d, %v", cursor, err)
+	}
+}
+
+// A crash between truncating the log and resetting the cursor used to leave
+// the cursor past the end, hiding every record appended afterwards
+func TestOpenRewindsCursorPastEnd(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, recordsFile), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, cursorFile), []byte("4096\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Append("diff", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if depth, err := o.Depth(); err != nil || depth != 1 {
+		t.Fatalf("depth = %d, %v; want 1", depth, err)
+	}
+}
+
+func TestOpenDropsTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	line := encodeRecord("diff", []byte(`{"n":1}`))
+	torn := append(append([]byte{}, line...), line[:5]...)
+	if err := os.WriteFile(filepath.Join(dir, recordsFile), torn, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err := OpenDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if depth, err := o.Depth(); err != nil || depth != 1 {
+		t.Fatalf("depth = %d, %v; want 1", depth, err)
+	}
+}
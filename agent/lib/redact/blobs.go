@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// DiffBlob redacts every patch in b and records the counts on the blob
+func (r *Redactor) DiffBlob(b types.DiffBlob) types.DiffBlob {
+	counts := map[string]int{}
+
+	changes := make([]types.FileChange, len(b.Changes))
+	for i, c := range b.Changes {
+		if c.Patch != nil {
+			c.Patch = &types.PatchInfo{DiffText: r.String(c.Patch.DiffText, counts)}
+		}
+		changes[i] = c
+	}
+	b.Changes = changes
+
+	if len(counts) > 0 {
+		b.Redactions = counts
+	}
+	return b
+}
+
+// CmdDiffBlob redacts every command line and captured stderr in b
+func (r *Redactor) CmdDiffBlob(b types.CmdDiffBlob) types.CmdDiffBlob {
+	counts := map[string]int{}
+
+	commands := make([]types.CommandEntry, len(b.Commands))
+	for i, c := range b.Commands {
+		c.Command = r.String(c.Command, counts)
+		if c.Stderr != nil {
+			stderr := r.String(*c.Stderr, counts)
+			c.Stderr = &stderr
+		}
+		commands[i] = c
+	}
+	b.Commands = commands
+
+	if len(counts) > 0 {
+		b.Redactions = counts
+	}
+	return b
+}
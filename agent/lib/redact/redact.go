@@ -0,0 +1,252 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// detector finds one kind of secret; when group > 0 only that submatch is replaced
+type detector struct {
+	kind  string
+	re    *regexp.Regexp
+	group int
+	// check, when set, must accept the match before it is redacted; m holds
+	// the submatch indices into s
+	check func(s string, m []int) bool
+}
+
+// builtins run in order; broader detectors come last so specific kinds win
+var builtins = []detector{
+	{kind: "private_key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)(-----END [A-Z ]*PRIVATE KEY-----|\z)`)},
+	{kind: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{kind: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "aws_secret_key", re: regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`), group: 1},
+	{kind: "auth_header", re: regexp.MustCompile(`(?i)authorization:\s*(?:bearer|basic|token)\s+([^\s'"]+)`), group: 1},
+	// groups: 1 name, 2 operator, 3 opening quote, 4 value
+	{kind: "password", re: regexp.MustCompile(`(?i)([\w.-]*(?:password|passwd|pwd|secret|token|api[_-]?key)[\w.-]*)["']?\s*(:=|=|:)\s*(["']?)([^\s"']+)`), group: 4, check: literalAssignment},
+	{kind: "high_entropy", re: regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`), check: randomToken},
+}
+
+// secretWords are the name parts that mark a value as a secret
+var secretWords = []string{"password", "passwd", "pwd", "secret", "token", "apikey"}
+
+// Redactor replaces secrets with placeholders like [REDACTED:jwt:1a2b3c4d].
+// The suffix is an HMAC of the secret under a per-project key, so the same
+// secret always gets the same placeholder without the value being guessable.
+type Redactor struct {
+	key       []byte
+	detectors []detector
+}
+
+// NewRedactor loads (or creates) .daemon/redact.key and compiles the user's extra patterns.
+// A user pattern with a capture group redacts only the first group.
+func NewRedactor(projectPath string, patterns []string) (*Redactor, error) {
+	key, err := loadKey(filepath.Join(projectPath, ".daemon", "redact.key"))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Redactor{key: key, detectors: append([]detector(nil), builtins...)}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		// user rules go first so they win over the generic detectors
+		r.detectors = append([]detector{{kind: "custom", re: re, group: group}}, r.detectors...)
+	}
+
+	return r, nil
+}
+
+// String redacts s, adding one to counts[kind] for every secret replaced
+func (r *Redactor) String(s string, counts map[string]int) string {
+	for _, d := range r.detectors {
+		s = r.apply(d, s, counts)
+	}
+	return s
+}
+
+func (r *Redactor) apply(d detector, s string, counts map[string]int) string {
+	matches := d.re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*d.group], m[2*d.group+1]
+		if start < 0 {
+			continue
+		}
+		secret := s[start:end]
+		// an earlier detector may already have replaced this value
+		if strings.HasPrefix(secret, "[REDACTED:") {
+			continue
+		}
+		if d.check != nil && !d.check(s, m) {
+			continue
+		}
+
+		b.WriteString(s[last:start])
+		b.WriteString(r.placeholder(d.kind, secret))
+		last = end
+		counts[d.kind]++
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func (r *Redactor) placeholder(kind, secret string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(secret))
+	return fmt.Sprintf("[REDACTED:%s:%s]", kind, hex.EncodeToString(mac.Sum(nil))[:8])
+}
+
+func loadKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) >= 32 {
+		return key, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read redaction key: %w", err)
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate redaction key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create .daemon directory: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write redaction key: %w", err)
+	}
+	return key, nil
+}
+
+// literalAssignment accepts a password match when the name has a secret word
+// in it, not just its letters ("tokenizerName"), and the value is a literal:
+// quoted, or a bare word that isn't code like `:= bcrypt(pw)` or `== nil`.
+func literalAssignment(s string, m []int) bool {
+	if !hasSecretWord(s[m[2]:m[3]]) {
+		return false
+	}
+	if m[6] < m[7] {
+		return true
+	}
+
+	op, value := s[m[4]:m[5]], s[m[8]:m[9]]
+	if op == ":=" {
+		return false
+	}
+	return !strings.ContainsAny(value, "()[]{}") && !strings.HasPrefix(value, "=") && !strings.HasPrefix(value, "$")
+}
+
+// hasSecretWord splits an identifier at _ - . and camelCase humps and
+// reports whether a part ends in a secret word, so "dbPassword",
+// "GITHUB_TOKEN" and "api_key" count but "tokenizer" doesn't
+func hasSecretWord(name string) bool {
+	var words []string
+	start := 0
+	for i, c := range name {
+		switch {
+		case c == '_' || c == '-' || c == '.':
+			words = append(words, name[start:i])
+			start = i + 1
+		case i > start && unicode.IsUpper(c) && unicode.IsLower(rune(name[i-1])):
+			words = append(words, name[start:i])
+			start = i
+		}
+	}
+	words = append(words, name[start:])
+
+	for i, w := range words {
+		w = strings.ToLower(w)
+		// "api_key" and "apiKey" are split in two
+		if w == "key" && i > 0 && strings.EqualFold(words[i-1], "api") {
+			return true
+		}
+		for _, secret := range secretWords {
+			if strings.HasSuffix(w, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// randomToken accepts a high_entropy match that isn't part of a path,
+// hostname or dotted name: base64 and hex never contain a dot, so a token
+// next to one is a file name like MemberActivityPage2Helpers.tsx
+func randomToken(s string, m []int) bool {
+	start, end := m[0], m[1]
+	token := s[start:end]
+
+	if start > 0 && (s[start-1] == '.' || s[start-1] == '/') {
+		return false
+	}
+	if end+1 < len(s) && s[end] == '.' && isAlnum(s[end+1]) {
+		return false
+	}
+	// a/b/c with two or more separators and no base64 padding or plus signs
+	if strings.Count(token, "/") >= 2 && !strings.ContainsAny(token, "+=") {
+		return false
+	}
+	return highEntropy(token)
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// highEntropy flags random-looking tokens; pure hex is skipped so git hashes survive
+func highEntropy(s string) bool {
+	hasDigit, hasLetter, hexOnly := false, false, true
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		case c >= 'a' && c <= 'f', c >= 'A' && c <= 'F':
+			hasLetter = true
+		case c >= 'g' && c <= 'z', c >= 'G' && c <= 'Z':
+			hasLetter = true
+			hexOnly = false
+		default:
+			hexOnly = false
+		}
+	}
+	if hexOnly || !hasDigit || !hasLetter {
+		return false
+	}
+	return shannon(s) >= 4.2
+}
+
+func shannon(s string) float64 {
+	freq := map[rune]float64{}
+	for _, c := range s {
+		freq[c]++
+	}
+
+	n := float64(len(s))
+	h := 0.0
+	for _, f := range freq {
+		p := f / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
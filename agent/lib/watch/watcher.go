@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// quietPeriod is how long the tree must stay still before a burst is reported
+	quietPeriod = 2 * time.Second
+	// maxDelay bounds how long a continuous stream of writes can postpone a snapshot
+	maxDelay = 30 * time.Second
+)
+
+var ErrWatchUnsupported = errors.New("filesystem watching is not supported on this platform")
+
+// debounce collapses bursts of raw events into single notifications on out
+func debounce(raw <-chan struct{}, out chan<- struct{}, done <-chan struct{}) {
+	var quiet, deadline <-chan time.Time
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-raw:
+			if deadline == nil {
+				deadline = time.After(maxDelay)
+			}
+			quiet = time.After(quietPeriod)
+			continue
+		case <-quiet:
+		case <-deadline:
+		}
+
+		quiet, deadline = nil, nil
+		select {
+		case out <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,235 @@
+//go:build linux
+
+package lib
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	gitlib "github.com/internal-hackathon-7/int-hack-7/agent/lib/git"
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_FROM |
+	unix.IN_MOVED_TO | unix.IN_ATTRIB | unix.IN_DELETE_SELF
+
+// Watcher reports debounced changes anywhere in a project via inotify,
+// skipping everything matched by .gitignore or daemon_ignore
+type Watcher struct {
+	root         string
+	daemonIgnore []string
+	// fd is the inotify descriptor, for adding watches. file.Fd() is never
+	// called: on files os made non-blocking itself it switches the fd back
+	// to blocking, and a blocking Read is one Close can't interrupt.
+	fd        int
+	file      *os.File
+	changes   chan struct{}
+	raw       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	// stopped is closed when readLoop returns
+	stopped chan struct{}
+
+	// mu guards dirs, matcher and daemonIgnore between the read loop and SetDaemonIgnore
+	mu sync.Mutex
+	// dirs maps watch descriptors to directories relative to root
+	dirs    map[int32]string
+	matcher gitignore.Matcher
+}
+
+func NewWatcher(root string, daemonIgnore []string) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init failed: %w", err)
+	}
+
+	w := &Watcher{
+		root:         root,
+		daemonIgnore: daemonIgnore,
+		fd:           fd,
+		// a non-blocking fd goes through the runtime poller, so Close unblocks Read
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		changes: make(chan struct{}, 1),
+		raw:     make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		dirs:    map[int32]string{},
+	}
+
+	if err := w.reloadIgnore(); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	if err := w.addTree(""); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	log.Printf("watching %d directories under %s", len(w.dirs), root)
+
+	go w.readLoop()
+	go debounce(w.raw, w.changes, w.done)
+
+	return w, nil
+}
+
+// Changes fires once per debounced burst of filesystem activity
+func (w *Watcher) Changes() <-chan struct{} {
+	return w.changes
+}
+
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
+
+// SetDaemonIgnore swaps the daemon_ignore patterns, watching any directory
+// they no longer exclude
+func (w *Watcher) SetDaemonIgnore(patterns []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.daemonIgnore = patterns
+	if err := w.reloadIgnore(); err != nil {
+		return err
+	}
+	return w.addTree("")
+}
+
+// addTree watches rel and every non-ignored directory beneath it
+func (w *Watcher) addTree(rel string) error {
+	return filepath.WalkDir(filepath.Join(w.root, rel), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			// the directory may vanish mid-walk
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(w.root, path)
+		if relPath != "." && w.ignored(relPath, true) {
+			return filepath.SkipDir
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, path, watchMask)
+		if err != nil {
+			if err == unix.ENOSPC {
+				return fmt.Errorf("inotify watch limit reached, raise fs.inotify.max_user_watches: %w", err)
+			}
+			return nil
+		}
+
+		if relPath == "." {
+			relPath = ""
+		}
+		w.dirs[int32(wd)] = relPath
+		return nil
+	})
+}
+
+func (w *Watcher) readLoop() {
+	defer close(w.stopped)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			select {
+			case <-w.done:
+			default:
+				log.Printf("inotify read error, watcher stopped: %v", err)
+			}
+			return
+		}
+
+		w.mu.Lock()
+		changed := false
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(ev.Len)]
+			name := strings.TrimRight(string(nameBytes), "\x00")
+			offset += unix.SizeofInotifyEvent + int(ev.Len)
+
+			if w.handle(ev, name) {
+				changed = true
+			}
+		}
+		w.mu.Unlock()
+
+		if changed {
+			select {
+			case w.raw <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// handle updates the watch set for one event and reports whether it touched a tracked path
+func (w *Watcher) handle(ev *unix.InotifyEvent, name string) bool {
+	if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+		// events were dropped; assume something changed
+		return true
+	}
+
+	dir, ok := w.dirs[ev.Wd]
+	if !ok {
+		return false
+	}
+
+	if ev.Mask&unix.IN_IGNORED != 0 {
+		delete(w.dirs, ev.Wd)
+		return false
+	}
+
+	if name == "" {
+		// the watched directory itself was deleted
+		return ev.Mask&unix.IN_DELETE_SELF != 0
+	}
+
+	rel := filepath.Join(dir, name)
+	isDir := ev.Mask&unix.IN_ISDIR != 0
+
+	if w.ignored(rel, isDir) {
+		return false
+	}
+
+	if name == ".gitignore" {
+		if err := w.reloadIgnore(); err != nil {
+			log.Printf("could not reload ignore patterns: %v", err)
+		}
+	}
+
+	if isDir && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		if err := w.addTree(rel); err != nil {
+			log.Printf("could not watch %s: %v", rel, err)
+		}
+	}
+
+	return true
+}
+
+func (w *Watcher) reloadIgnore() error {
+	m, err := gitlib.IgnoreMatcher(w.root, w.daemonIgnore)
+	if err != nil {
+		return err
+	}
+	w.matcher = m
+	return nil
+}
+
+func (w *Watcher) ignored(rel string, isDir bool) bool {
+	return w.matcher.Match(strings.Split(filepath.ToSlash(rel), "/"), isDir)
+}
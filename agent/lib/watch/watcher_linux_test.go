@@ -0,0 +1,66 @@
+//go:build linux
+
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestWatcherReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.Changes():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+}
+
+// Close must unblock the read loop even when no event ever arrives;
+// adding a watch must not have put the fd back in blocking mode
+func TestWatcherCloseStopsReadLoop(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub", "deeper"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// watches added after the read loop started too
+	if err := w.SetDaemonIgnore([]string{"build/"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	flags, err := unix.FcntlInt(uintptr(w.fd), unix.F_GETFL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags&unix.O_NONBLOCK == 0 {
+		t.Fatal("inotify fd is in blocking mode")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("readLoop still running after Close")
+	}
+}
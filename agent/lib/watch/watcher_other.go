@@ -0,0 +1,22 @@
+//go:build !linux
+
+package lib
+
+// Watcher is only implemented on linux; elsewhere the agent polls
+type Watcher struct{}
+
+func NewWatcher(root string, daemonIgnore []string) (*Watcher, error) {
+	return nil, ErrWatchUnsupported
+}
+
+func (w *Watcher) Changes() <-chan struct{} {
+	return nil
+}
+
+func (w *Watcher) SetDaemonIgnore(patterns []string) error {
+	return nil
+}
+
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
+	"syscall"
 
 	"github.com/internal-hackathon-7/int-hack-7/agent/config"
 	"github.com/internal-hackathon-7/int-hack-7/agent/constants"
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
 	"github.com/joho/godotenv"
 )
 
@@ -18,68 +22,108 @@ const CREATE_NEW_PROCESS_GROUP = 0x00000200
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Printf("Usage: %s <command>", config.DisplayName)
-		fmt.Println("Commands: init")
+		fmt.Println("Commands: init, run, supervise, add, remove, stop, status, restart, snapshot, pause, resume, reload, flush, events, ignore, shell-hook")
 		return
 	}
 
-	err := godotenv.Load(".env")
-	if err != nil {
-		log.Fatalf("Error loading .env file")
+	// hooks run after every shell command: keep them fast and independent of .env
+	switch os.Args[1] {
+	case "shell-hook":
+		if err := config.ShellHookCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "hook-report":
+		config.HookReportCommand(os.Args[2:])
+		return
+
+	// process control only touches .daemon; a restarted agent loads .env itself
+	case "stop":
+		if err := config.StopCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "status":
+		if err := config.StatusCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "restart":
+		if err := config.RestartCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+
+	// thin clients of a running agent's control socket
+	case "snapshot", "pause", "resume", "reload", "flush":
+		if err := config.ControlCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "events":
+		if err := config.EventsCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// provisioning scripts may pass everything through the environment instead
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
 	}
 
 	constants.MasterURL = os.Getenv("MasterURL")
 
 	switch os.Args[1] {
 	case "init":
-		if err := config.PingMaster(); err != nil {
-			log.Fatal("Connection NOT established! Service down")
-		}
-		projectPath, interval, emailID, err := config.InitCommand()
+		projectPath, err := config.InitCommand(os.Args[2:])
 		if err != nil {
 			fmt.Println("Error:", err)
+			os.Exit(1)
 		}
 
-		exePath, err := os.Executable()
+		pid, err := config.StartBackground(projectPath)
 		if err != nil {
-			log.Fatalf("Failed to get executable path: %v", err)
-		}
-
-		// example: replace below with your actual command & flags
-		cmd := exec.Command(exePath,
-			"run",
-			"-path", projectPath,
-			"-interval", fmt.Sprintf("%d", interval),
-			"-email", emailID,
-		)
-
-		// detach from terminal (run in background)
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		cmd.Stdin = nil
-
-		config.SetDetachAttr(cmd)
-
-		if err := cmd.Start(); err != nil {
-			log.Fatalf("Failed to start background command: %v", err)
+			log.Fatal(err)
 		}
 
-		fmt.Printf("Started background process with PID %d\n", cmd.Process.Pid)
+		fmt.Printf("Started background process with PID %d\n", pid)
 
 	case "run":
-		initCmd := flag.NewFlagSet("run", flag.ExitOnError)
-		projectPath := initCmd.String("path", ".", "Path to the project directory to monitor")
-		interval := initCmd.Int("interval", 10, "Polling interval in seconds (integer only)")
-		email := initCmd.String("email", "", "Email address for identification or notifications")
-
-		if err := initCmd.Parse(os.Args[2:]); err != nil {
+		runCmd := flag.NewFlagSet("run", flag.ExitOnError)
+		var flags config.Overrides
+		runCmd.StringVar(&flags.Path, "path", "", "Path to the project directory to monitor (default .)")
+		runCmd.Func("interval", "Polling interval like 30s or 5m, overrides the config", func(v string) error {
+			d, err := types.ParseInterval(v)
+			flags.Interval = d
+			return err
+		})
+		runCmd.StringVar(&flags.Email, "email", "", "Email address, overrides the config")
+		runCmd.StringVar(&flags.Room, "room", "", "Room ID, overrides the config")
+		runCmd.StringVar(&flags.MasterURL, "master-url", "", "Master server URL, overrides the config")
+
+		if err := runCmd.Parse(os.Args[2:]); err != nil {
 			log.Fatal(err)
 		}
 
-		daemonDir := fmt.Sprintf("%s/.daemon", *projectPath)
+		cfg, err := config.Load(flags)
+		if err != nil {
+			log.Fatalf("Invalid config:\n%v", err)
+		}
+		projectPath := cfg.ProjectPath
+
+		daemonDir := fmt.Sprintf("%s/.daemon", projectPath)
 		if err := os.MkdirAll(daemonDir, 0755); err != nil {
 			log.Fatalf("Failed to create daemon directory: %v", err)
 		}
 
+		// refuse to start before logging moves to the file, so the user sees why
+		pidFile, err := config.LockPIDFile(projectPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer pidFile.Release()
+
 		// --- SETUP LOG FILE ---
 		logFilePath := fmt.Sprintf("%s/agent.log", daemonDir)
 		logFile, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
@@ -92,42 +136,51 @@ func main() {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
 
 		log.Println("Agent starting up...")
-		log.Println("email :", email)
-		log.Printf("Monitoring path: %s\n", *projectPath)
-		log.Printf("Interval: %d seconds\n", *interval)
+		log.Println("email :", cfg.EmailID)
+		log.Printf("Monitoring path: %s\n", projectPath)
+		log.Printf("Interval: %s\n", cfg.Interval)
 
-		// --- append to PID FILE ---
-		pidFilePath := fmt.Sprintf("%s/agent.pid", daemonDir)
-		pid := os.Getpid()
+		log.Printf("PID %d locked in %s/agent.pid\n", os.Getpid(), daemonDir)
 
-		// Open (or create) the file, wiping any previous content
-		f, err := os.OpenFile(pidFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-		if err != nil {
-			log.Fatalf("Failed to open PID file: %v", err)
+		if err := config.EnsureGitRepo(projectPath); err != nil {
+			log.Printf("Warning: could not get GIT : %v\n", err)
+		}
+
+		if err := config.EnsureDaemonInGitignore(projectPath); err != nil {
+			log.Printf("Warning: could not update .gitignore: %v\n", err)
 		}
-		defer f.Close()
 
-		// Write the PID (kill friendly)
-		if _, err := f.WriteString(fmt.Sprintf("%d\n", pid)); err != nil {
-			log.Fatalf("Failed to write PID to file: %v", err)
+		// --- START SERVICE ---
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := config.StartService(ctx, cfg, flags); err != nil {
+			log.Printf("Agent stopped: %v\n", err)
+			pidFile.Release()
+			os.Exit(1)
 		}
 
-		log.Printf("PID %d written fresh to %s\n", pid, pidFilePath)
+		log.Println("Agent shutting down.")
 
-		if err := config.EnsureGitRepo(*projectPath); err != nil {
-			log.Printf("Warning: could not get GIT : %v\n", err)
+	case "supervise":
+		if err := config.SuperviseCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
 		}
 
-		if err := config.EnsureDaemonInGitignore(*projectPath); err != nil {
-			log.Printf("Warning: could not update .gitignore: %v\n", err)
+	case "add":
+		if err := config.AddCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
 		}
 
-		// --- START SERVICE ---
-		config.StartService(*projectPath, *interval)
+	case "remove":
+		if err := config.RemoveCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
 
-		// --- CLEANUP WHEN EXITING ---
-		os.Remove(pidFilePath)
-		log.Println("Agent shutting down.")
+	case "ignore":
+		if err := config.IgnoreCommand(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
 
 	default:
 		fmt.Println("Unknown command:", os.Args[1])
@@ -6,11 +6,21 @@ type CmdDiffBlob struct {
 	// SessionID string         `json:"session_id"`
 	// User      string         `json:"user"`
 	Commands []CommandEntry `json:"commands"`
+	// Redactions counts secrets removed from the commands, by detector kind
+	Redactions map[string]int `json:"redactions,omitempty"`
 }
 
+// UnknownExitCode marks commands recovered from history files, which don't record exit status
+const UnknownExitCode = -1
+
 type CommandEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Command   string    `json:"command"`
-	ExitCode  int       `json:"exit_code"`
-	Stderr    *string   `json:"stderr,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Command   string        `json:"command"`
+	ExitCode  int           `json:"exit_code"`
+	Duration  time.Duration `json:"duration"`
+	Cwd       string        `json:"cwd,omitempty"`
+	Stderr    *string       `json:"stderr,omitempty"`
+	// Paths are the files a command referenced (fish history); only used to
+	// attribute it to a project and never uploaded
+	Paths []string `json:"-"`
 }
@@ -1,13 +1,90 @@
 package types
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 type ProjectConfig struct {
-	MasterURL string `yaml:"master_url"`
-	RoomID    string `yaml:"room_id"`
-	Interval  int    `yaml:"interval_minutes"`
-	AuthToken string `yaml:"auth_token"`
+	// SchemaVersion is the config.yaml layout; older files are migrated on load
+	SchemaVersion int    `yaml:"schema_version"`
+	MasterURL     string `yaml:"master_url"`
+	RoomID        string `yaml:"room_id"`
+	// Interval is the requested tick period, written like "30s", "5m" or 30 seconds
+	Interval time.Duration `yaml:"interval"`
+	// IntervalMin and IntervalMax are the room's bounds on Interval, as last
+	// handed out by the master; zero means unbounded
+	IntervalMin time.Duration `yaml:"interval_min,omitempty"`
+	IntervalMax time.Duration `yaml:"interval_max,omitempty"`
 	// WatchDirs       []string `yaml:"watch_dirs"`
 	ProjectPath  string   `yaml:"project_path"`
 	DaemonIgnore []string `yaml:"daemon_ignore"`
 	EmailID      string   `yaml:"email_id"`
+	MemberID     string   `yaml:"member_id"`
 	DefaultShell string   `yaml:"default_shell"`
+	// PollOnly disables the filesystem watcher and snapshots on every tick instead
+	PollOnly bool `yaml:"poll_only,omitempty"`
+	// RedactPatterns are extra regexes scrubbed from patches and commands before upload
+	RedactPatterns []string `yaml:"redact_patterns,omitempty"`
+	// RenameThreshold is the similarity percentage for rename/copy detection
+	RenameThreshold int `yaml:"rename_threshold,omitempty"`
+	// ExtraPaths are directories outside ProjectPath whose commands are also recorded
+	ExtraPaths []string `yaml:"extra_paths,omitempty"`
+	// CommandAllow, when set, keeps only commands starting with one of these prefixes
+	CommandAllow []string `yaml:"command_allow,omitempty"`
+	// CommandDeny drops commands starting with one of these prefixes, e.g. "pass" or "ssh"
+	CommandDeny []string `yaml:"command_deny,omitempty"`
+	// RecordUnattributed keeps history commands that can't be tied to a directory
+	RecordUnattributed bool `yaml:"record_unattributed,omitempty"`
+}
+
+// UnmarshalYAML reads the interval fields through ParseInterval, so the file
+// takes a bare number of seconds like -interval and DAEMON_INTERVAL do
+func (c *ProjectConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain ProjectConfig
+
+	if node.Kind == yaml.MappingNode {
+		copied := *node
+		copied.Content = append([]*yaml.Node(nil), node.Content...)
+		for i := 0; i+1 < len(copied.Content); i += 2 {
+			key, value := copied.Content[i], copied.Content[i+1]
+			switch key.Value {
+			case "interval", "interval_min", "interval_max":
+			default:
+				continue
+			}
+			if value.Kind != yaml.ScalarNode {
+				continue
+			}
+
+			d, err := ParseInterval(value.Value)
+			if err != nil {
+				return fmt.Errorf("line %d: %s: %w", value.Line, key.Value, err)
+			}
+			normalized := *value
+			normalized.Tag, normalized.Value = "!!str", d.String()
+			copied.Content[i+1] = &normalized
+		}
+		node = &copied
+	}
+
+	return node.Decode((*plain)(c))
+}
+
+// ParseInterval reads a period like "30s" or "5m". A bare number is seconds,
+// as DAEMON_INTERVAL and -interval always were.
+func ParseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration like 30s or 5m", s)
+	}
+	return d, nil
 }
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestProjectConfigIntervalYAML(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantMin time.Duration
+	}{
+		{"interval: 30", 30 * time.Second, 0},
+		{"interval: 45s", 45 * time.Second, 0},
+		{`interval: "2m"`, 2 * time.Minute, 0},
+		{"interval: 1m30s\ninterval_min: 15", 90 * time.Second, 15 * time.Second},
+	}
+
+	for _, tt := range tests {
+		var cfg ProjectConfig
+		if err := yaml.Unmarshal([]byte(tt.in), &cfg); err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if cfg.Interval != tt.want || cfg.IntervalMin != tt.wantMin {
+			t.Errorf("%q: interval %s min %s, want %s min %s", tt.in, cfg.Interval, cfg.IntervalMin, tt.want, tt.wantMin)
+		}
+	}
+
+	var cfg ProjectConfig
+	if err := yaml.Unmarshal([]byte("interval: soon"), &cfg); err == nil {
+		t.Error("interval: soon was accepted")
+	}
+
+	// config layers decode onto what earlier ones set
+	cfg = ProjectConfig{EmailID: "dev@example.com"}
+	if err := yaml.Unmarshal([]byte("interval: 30"), &cfg); err != nil || cfg.EmailID != "dev@example.com" {
+		t.Errorf("layering lost email_id: %+v, %v", cfg, err)
+	}
+}
+
+func TestProjectConfigYAMLRoundTrip(t *testing.T) {
+	in := ProjectConfig{RoomID: "room", Interval: 30 * time.Second, IntervalMax: 10 * time.Minute, PollOnly: true}
+
+	data, err := yaml.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out ProjectConfig
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.RoomID != in.RoomID || out.Interval != in.Interval || out.IntervalMax != in.IntervalMax || !out.PollOnly {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+}
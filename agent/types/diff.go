@@ -7,6 +7,8 @@ type DiffBlob struct {
 	Timestamp   string       `json:"timestamp"`
 	Summary     SummaryInfo  `json:"summary"`
 	Changes     []FileChange `json:"changes"`
+	// Redactions counts secrets removed from the patches, by detector kind
+	Redactions map[string]int `json:"redactions,omitempty"`
 }
 
 type SummaryInfo struct {
@@ -27,6 +29,7 @@ type FileChange struct {
 	HashAfter    *string    `json:"hash_after,omitempty"`
 	LinesAdded   int        `json:"lines_added"`
 	LinesDeleted int        `json:"lines_deleted"`
+	Similarity   int        `json:"similarity,omitempty"`
 	Patch        *PatchInfo `json:"patch,omitempty"`
 }
 
@@ -0,0 +1,156 @@
+package wire
+
+import (
+	"time"
+
+	"github.com/internal-hackathon-7/int-hack-7/agent/types"
+)
+
+// actionNames maps the agent's change actions to the names the dashboard renders
+var actionNames = map[string]string{
+	"insert": "added",
+	"delete": "deleted",
+	"modify": "modified",
+	"rename": "renamed",
+	"copy":   "copied",
+}
+
+func FromDiffBlob(roomID, memberID string, b types.DiffBlob) DiffBlob {
+	out := DiffBlob{
+		SchemaVersion: SchemaVersion,
+		RoomID:        roomID,
+		MemberID:      memberID,
+		ProjectName:   b.ProjectName,
+		OldHash:       b.OldHash,
+		NewHash:       b.NewHash,
+		Timestamp:     b.Timestamp,
+		Summary: SummaryInfo{
+			FilesChanged: b.Summary.FilesChanged,
+			Insertions:   b.Summary.Insertions,
+			Deletions:    b.Summary.Deletions,
+			Renames:      b.Summary.Renames,
+			Copies:       b.Summary.Copies,
+		},
+		Changes:    make([]FileChange, 0, len(b.Changes)),
+		Redactions: b.Redactions,
+	}
+
+	for _, c := range b.Changes {
+		fc := FileChange{
+			Action:       mapAction(actionNames, c.Action),
+			OldPath:      c.OldPath,
+			NewPath:      c.NewPath,
+			OldMode:      c.OldMode,
+			NewMode:      c.NewMode,
+			HashBefore:   c.HashBefore,
+			HashAfter:    c.HashAfter,
+			LinesAdded:   c.LinesAdded,
+			LinesDeleted: c.LinesDeleted,
+			Similarity:   c.Similarity,
+		}
+		if c.Patch != nil {
+			fc.Patch = &PatchInfo{DiffText: c.Patch.DiffText}
+		}
+		out.Changes = append(out.Changes, fc)
+	}
+
+	return out
+}
+
+// ToDiffBlob is the inverse of FromDiffBlob; roomId and memberId are dropped
+func ToDiffBlob(w DiffBlob) types.DiffBlob {
+	out := types.DiffBlob{
+		ProjectName: w.ProjectName,
+		OldHash:     w.OldHash,
+		NewHash:     w.NewHash,
+		Timestamp:   w.Timestamp,
+		Summary: types.SummaryInfo{
+			FilesChanged: w.Summary.FilesChanged,
+			Insertions:   w.Summary.Insertions,
+			Deletions:    w.Summary.Deletions,
+			Renames:      w.Summary.Renames,
+			Copies:       w.Summary.Copies,
+		},
+		Redactions: w.Redactions,
+	}
+
+	for _, c := range w.Changes {
+		fc := types.FileChange{
+			Action:       mapAction(invert(actionNames), c.Action),
+			OldPath:      c.OldPath,
+			NewPath:      c.NewPath,
+			OldMode:      c.OldMode,
+			NewMode:      c.NewMode,
+			HashBefore:   c.HashBefore,
+			HashAfter:    c.HashAfter,
+			LinesAdded:   c.LinesAdded,
+			LinesDeleted: c.LinesDeleted,
+			Similarity:   c.Similarity,
+		}
+		if c.Patch != nil {
+			fc.Patch = &types.PatchInfo{DiffText: c.Patch.DiffText}
+		}
+		out.Changes = append(out.Changes, fc)
+	}
+
+	return out
+}
+
+func FromCmdDiffBlob(roomID, memberID, projectName string, b types.CmdDiffBlob) CmdDiffBlob {
+	out := CmdDiffBlob{
+		SchemaVersion: SchemaVersion,
+		RoomID:        roomID,
+		MemberID:      memberID,
+		ProjectName:   projectName,
+		Commands:      make([]CommandEntry, 0, len(b.Commands)),
+		Redactions:    b.Redactions,
+	}
+
+	for _, c := range b.Commands {
+		out.Commands = append(out.Commands, CommandEntry{
+			Timestamp:  c.Timestamp.UTC().Format(time.RFC3339),
+			Command:    c.Command,
+			ExitCode:   c.ExitCode,
+			DurationMs: c.Duration.Milliseconds(),
+			Cwd:        c.Cwd,
+			Stderr:     c.Stderr,
+		})
+	}
+
+	return out
+}
+
+// ToCmdDiffBlob is the inverse of FromCmdDiffBlob; unparsable timestamps become the zero time
+func ToCmdDiffBlob(w CmdDiffBlob) types.CmdDiffBlob {
+	out := types.CmdDiffBlob{Redactions: w.Redactions}
+
+	for _, c := range w.Commands {
+		ts, _ := time.Parse(time.RFC3339, c.Timestamp)
+		out.Commands = append(out.Commands, types.CommandEntry{
+			Timestamp: ts,
+			Command:   c.Command,
+			ExitCode:  c.ExitCode,
+			Duration:  time.Duration(c.DurationMs) * time.Millisecond,
+			Cwd:       c.Cwd,
+			Stderr:    c.Stderr,
+		})
+	}
+
+	return out
+}
+
+// mapAction renames a known action and passes unknown ones through untouched
+func mapAction(names map[string]string, action string) string {
+	if mapped, ok := names[action]; ok {
+		return mapped
+	}
+	return action
+}
+
+func invert(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[v] = k
+	}
+	return out
+}
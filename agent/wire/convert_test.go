@@ -0,0 +1,159 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// modelDir holds the master's Mongoose models the fixtures are checked against
+var modelDir = filepath.Join("..", "..", "master", "src", "model")
+
+// The fixtures are documents as the master stores them. Each test decodes one
+// strictly, converts it to the agent's type and back, and must get the same
+// document out.
+
+func TestDiffBlobMasterFixture(t *testing.T) {
+	fixture := readFixture(t, "diffblob.master.json")
+	checkSchemaFields(t, fixture, "DiffBlobs.ts")
+
+	var w DiffBlob
+	decodeStrict(t, fixture, &w)
+
+	blob := ToDiffBlob(w)
+	if blob.Changes[0].Action != "modify" || blob.Changes[2].Action != "copy" {
+		t.Errorf("actions = %q, %q; want the agent's names", blob.Changes[0].Action, blob.Changes[2].Action)
+	}
+
+	checkRoundTrip(t, fixture, FromDiffBlob(w.RoomID, w.MemberID, blob))
+}
+
+func TestCmdDiffBlobMasterFixture(t *testing.T) {
+	fixture := readFixture(t, "cmddiffblob.master.json")
+	checkSchemaFields(t, fixture, "CmdDiffBlobs.ts")
+
+	var w CmdDiffBlob
+	decodeStrict(t, fixture, &w)
+
+	blob := ToCmdDiffBlob(w)
+	if blob.Commands[0].Duration.Milliseconds() != 2500 {
+		t.Errorf("duration = %s, want 2.5s", blob.Commands[0].Duration)
+	}
+
+	checkRoundTrip(t, fixture, FromCmdDiffBlob(w.RoomID, w.MemberID, w.ProjectName, blob))
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// decodeStrict fails on any fixture field the wire type doesn't have
+func decodeStrict(t *testing.T, data []byte, out any) {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		t.Fatalf("fixture doesn't decode into %T: %v", out, err)
+	}
+}
+
+func checkRoundTrip(t *testing.T, fixture []byte, w any) {
+	t.Helper()
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want, got any
+	if err := json.Unmarshal(fixture, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the document:\n got %s\nwant %s", data, fixture)
+	}
+}
+
+var (
+	schemaBlock = regexp.MustCompile(`(?s)new Schema<\w+>\(\{\n(.*?)\n\}\)`)
+	schemaField = regexp.MustCompile(`(?m)^  (\w+):`)
+)
+
+// checkSchemaFields compares the keys used anywhere in the fixture with the
+// fields declared by the Schemas in the model file. A field renamed or added
+// on either side fails here. The check is by name, not nesting.
+func checkSchemaFields(t *testing.T, fixture []byte, model string) {
+	t.Helper()
+	src, err := os.ReadFile(filepath.Join(modelDir, model))
+	if os.IsNotExist(err) {
+		t.Skipf("%s not found, is the master checked out?", model)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declared := map[string]bool{}
+	for _, block := range schemaBlock.FindAllSubmatch(src, -1) {
+		for _, field := range schemaField.FindAllSubmatch(block[1], -1) {
+			declared[string(field[1])] = true
+		}
+	}
+	if len(declared) == 0 {
+		t.Fatalf("no schema fields found in %s", model)
+	}
+
+	var doc any
+	if err := json.Unmarshal(fixture, &doc); err != nil {
+		t.Fatal(err)
+	}
+	used := map[string]bool{}
+	collectKeys(doc, used)
+
+	if missing := difference(used, declared); len(missing) > 0 {
+		t.Errorf("fixture fields not in %s: %v", model, missing)
+	}
+	if unused := difference(declared, used); len(unused) > 0 {
+		t.Errorf("%s fields missing from the fixture: %v", model, unused)
+	}
+}
+
+// collectKeys adds every object key under v to seen. redactions is a Map in
+// the schema, so its keys are data and not collected.
+func collectKeys(v any, seen map[string]bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			seen[k] = true
+			if k != "redactions" {
+				collectKeys(child, seen)
+			}
+		}
+	case []any:
+		for _, child := range v {
+			collectKeys(child, seen)
+		}
+	}
+}
+
+func difference(a, b map[string]bool) []string {
+	var out []string
+	for k := range a {
+		if !b[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,67 @@
+// Package wire converts the agent's internal types to the JSON schema the master stores.
+//
+// The master's Mongoose models use camelCase keys while the agent's types marshal as
+// snake_case, so every upload goes through this package instead of marshalling types directly.
+// Bump SchemaVersion whenever a field here is renamed, added or removed.
+package wire
+
+const SchemaVersion = 4
+
+// DiffBlob matches DiffBlobSchema in master/src/model/DiffBlobs.ts
+type DiffBlob struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	RoomID        string         `json:"roomId"`
+	MemberID      string         `json:"memberId"`
+	ProjectName   string         `json:"projectName"`
+	OldHash       string         `json:"oldHash"`
+	NewHash       string         `json:"newHash"`
+	Timestamp     string         `json:"timestamp,omitempty"`
+	Summary       SummaryInfo    `json:"summary"`
+	Changes       []FileChange   `json:"changes"`
+	Redactions    map[string]int `json:"redactions,omitempty"`
+}
+
+type SummaryInfo struct {
+	FilesChanged int `json:"filesChanged"`
+	Insertions   int `json:"insertions"`
+	Deletions    int `json:"deletions"`
+	Renames      int `json:"renames"`
+	Copies       int `json:"copies"`
+}
+
+type FileChange struct {
+	Action       string     `json:"action"`
+	OldPath      *string    `json:"oldPath,omitempty"`
+	NewPath      *string    `json:"newPath,omitempty"`
+	OldMode      string     `json:"oldMode,omitempty"`
+	NewMode      string     `json:"newMode,omitempty"`
+	HashBefore   *string    `json:"hashBefore,omitempty"`
+	HashAfter    *string    `json:"hashAfter,omitempty"`
+	LinesAdded   int        `json:"linesAdded"`
+	LinesDeleted int        `json:"linesDeleted"`
+	Similarity   int        `json:"similarity,omitempty"`
+	Patch        *PatchInfo `json:"patch,omitempty"`
+}
+
+type PatchInfo struct {
+	DiffText string `json:"diffText"`
+}
+
+// CmdDiffBlob matches CmdDiffBlobSchema in master/src/model/CmdDiffBlobs.ts
+type CmdDiffBlob struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	RoomID        string         `json:"roomId"`
+	MemberID      string         `json:"memberId"`
+	ProjectName   string         `json:"projectName"`
+	Commands      []CommandEntry `json:"commands"`
+	Redactions    map[string]int `json:"redactions,omitempty"`
+}
+
+type CommandEntry struct {
+	Timestamp  string  `json:"timestamp"`
+	Command    string  `json:"command"`
+	ExitCode   int     `json:"exitCode"`
+	DurationMs int64   `json:"durationMs"`
+	Cwd        string  `json:"cwd,omitempty"`
+	Stderr     *string `json:"stderr,omitempty"`
+}
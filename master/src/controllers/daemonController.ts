@@ -2,6 +2,7 @@ import { Router, type Request, type Response } from "express";
 import { Room } from "../model/Room.ts";
 import { User } from "../model/User.ts";
 import { DiffBlobModel } from "../model/DiffBlobs.ts";
+import { CmdDiffBlobModel } from "../model/CmdDiffBlobs.ts";
 
 export const roomRouter = Router();
 
@@ -15,14 +16,19 @@ export async function joinRoom(req: Request, res: Response) {
 
     const member = await User.findOne({ email: gmail });
     if (!member) {
-      return res.status(404).json({ error: "Member not found for this Gmail" });
+      return res.status(404).json({
+        error: "Member not found for this Gmail",
+        code: "MEMBER_NOT_FOUND",
+      });
     }
 
     const googleId = member.googleId;
 
     const room = await Room.findOne({ roomId });
     if (!room) {
-      return res.status(404).json({ error: "Room not found" });
+      return res
+        .status(404)
+        .json({ error: "Room not found", code: "ROOM_NOT_FOUND" });
     }
 
     if (!room.members.includes(googleId)) {
@@ -35,7 +41,13 @@ export async function joinRoom(req: Request, res: Response) {
 
     return res.json({
       message: "Member added successfully",
+      memberId: googleId,
       room,
+      interval: {
+        minSeconds: room.minIntervalSeconds,
+        defaultSeconds: room.defaultIntervalSeconds,
+        maxSeconds: room.maxIntervalSeconds,
+      },
     });
   } catch (error) {
     console.error("❌ Error adding member to room:", error);
@@ -70,13 +82,16 @@ export async function getUserRooms(req: Request, res: Response) {
 export const addDiffBlobs = async (req: Request, res: Response) => {
   try {
     const {
+      schemaVersion,
       roomId,
       memberId,
       projectName,
       oldHash,
       newHash,
+      timestamp,
       summary,
       changes,
+      redactions,
     } = req.body;
 
     // 🧩 Validation
@@ -88,6 +103,7 @@ export const addDiffBlobs = async (req: Request, res: Response) => {
 
     // 🧠 Create and Save DiffBlob
     const diffBlob = new DiffBlobModel({
+      schemaVersion,
       roomId,
       memberId,
       projectName,
@@ -95,7 +111,8 @@ export const addDiffBlobs = async (req: Request, res: Response) => {
       newHash,
       summary,
       changes,
-      timestamp: new Date(),
+      redactions,
+      timestamp: timestamp ? new Date(timestamp) : new Date(),
     });
 
     await diffBlob.save();
@@ -112,6 +129,49 @@ export const addDiffBlobs = async (req: Request, res: Response) => {
   }
 };
 
+export const addCmdDiffBlobs = async (req: Request, res: Response) => {
+  try {
+    const {
+      schemaVersion,
+      roomId,
+      memberId,
+      projectName,
+      commands,
+      redactions,
+    } = req.body;
+
+    // 🧩 Validation
+    if (!roomId || !memberId || !projectName) {
+      return res.status(400).json({
+        error: "roomId, memberId, and projectName are required fields.",
+      });
+    }
+
+    // 🧠 Create and Save CmdDiffBlob
+    const cmdDiffBlob = new CmdDiffBlobModel({
+      schemaVersion,
+      roomId,
+      memberId,
+      projectName,
+      commands,
+      redactions,
+      timestamp: new Date(),
+    });
+
+    await cmdDiffBlob.save();
+
+    console.log(`✅ CmdDiffBlob added for member ${memberId} in room ${roomId}`);
+
+    return res.status(201).json({
+      message: "CmdDiffBlob added successfully",
+      cmdDiffBlob,
+    });
+  } catch (error) {
+    console.error("❌ Error adding CmdDiffBlob:", error);
+    return res.status(500).json({ error: "Internal Server Error" });
+  }
+};
+
 export const fetchDiffBlobMember = async (req: Request, res: Response) => {
   try {
     const { roomId, googleId } = req.body;
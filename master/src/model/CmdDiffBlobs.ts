@@ -0,0 +1,44 @@
+import mongoose, { Schema, Document } from "mongoose";
+
+interface CommandEntry {
+  timestamp: Date;
+  command: string;
+  exitCode: number;
+  durationMs: number;
+  cwd?: string;
+  stderr?: string;
+}
+
+export interface CmdDiffBlob extends Document {
+  schemaVersion: number;
+  roomId: string;
+  memberId: string;
+  projectName: string;
+  timestamp: Date;
+  commands: CommandEntry[];
+  redactions?: Map<string, number>;
+}
+
+const CommandEntrySchema = new Schema<CommandEntry>({
+  timestamp: Date,
+  command: { type: String, required: true },
+  exitCode: Number,
+  durationMs: Number,
+  cwd: String,
+  stderr: String,
+});
+
+const CmdDiffBlobSchema = new Schema<CmdDiffBlob>({
+  schemaVersion: { type: Number, default: 1 },
+  roomId: { type: String, required: true, index: true },
+  memberId: { type: String, required: true, index: true },
+  projectName: { type: String, required: true },
+  timestamp: { type: Date, default: Date.now },
+  commands: [CommandEntrySchema],
+  redactions: { type: Map, of: Number },
+});
+
+export const CmdDiffBlobModel = mongoose.model<CmdDiffBlob>(
+  "CmdDiffBlob",
+  CmdDiffBlobSchema
+);
@@ -14,6 +14,7 @@ interface FileChange {
   hashAfter?: string;
   linesAdded: number;
   linesDeleted: number;
+  similarity?: number;
   patch?: PatchInfo;
 }
 
@@ -26,6 +27,7 @@ interface SummaryInfo {
 }
 
 export interface DiffBlob extends Document {
+  schemaVersion: number;
   roomId: string;
   memberId: string;
   projectName: string;
@@ -34,6 +36,7 @@ export interface DiffBlob extends Document {
   timestamp: Date;
   summary: SummaryInfo;
   changes: FileChange[];
+  redactions?: Map<string, number>;
 }
 
 const PatchSchema = new Schema<PatchInfo>({
@@ -50,6 +53,7 @@ const FileChangeSchema = new Schema<FileChange>({
   hashAfter: String,
   linesAdded: Number,
   linesDeleted: Number,
+  similarity: Number,
   patch: PatchSchema,
 });
 
@@ -62,6 +66,7 @@ const SummarySchema = new Schema<SummaryInfo>({
 });
 
 const DiffBlobSchema = new Schema<DiffBlob>({
+  schemaVersion: { type: Number, default: 1 },
   roomId: { type: String, required: true, index: true },
   memberId: { type: String, required: true, index: true },
   projectName: { type: String, required: true },
@@ -70,6 +75,7 @@ const DiffBlobSchema = new Schema<DiffBlob>({
   timestamp: { type: Date, default: Date.now },
   summary: SummarySchema,
   changes: [FileChangeSchema],
+  redactions: { type: Map, of: Number },
 });
 
 export const DiffBlobModel = mongoose.model<DiffBlob>(
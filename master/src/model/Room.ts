@@ -3,12 +3,19 @@ import mongoose, { Schema, Document } from "mongoose";
 export interface IRoom extends Document {
   roomId: string;
   members: string[]; // array of user googleIds
+  // bounds on how often agents in the room snapshot, in seconds
+  minIntervalSeconds: number;
+  defaultIntervalSeconds: number;
+  maxIntervalSeconds: number;
 }
 
 const RoomSchema = new Schema<IRoom>(
   {
     roomId: { type: String, required: true, unique: true },
     members: { type: [String], default: [] },
+    minIntervalSeconds: { type: Number, default: 5 },
+    defaultIntervalSeconds: { type: Number, default: 30 },
+    maxIntervalSeconds: { type: Number, default: 3600 },
   },
   { timestamps: true }
 );
@@ -3,6 +3,7 @@ import {
   joinRoom,
   getUserRooms,
   addDiffBlobs,
+  addCmdDiffBlobs,
   fetchDiffBlobMember,
 } from "../controllers/daemonController.ts";
 
@@ -11,6 +12,7 @@ const router = Router();
 router.post("/joinRoom", joinRoom);
 router.post("/roomsJoined", getUserRooms);
 router.post("/addDiffBlobs", addDiffBlobs);
+router.post("/addCmdDiffBlobs", addCmdDiffBlobs);
 router.post("/fetchDiffBlobMember", fetchDiffBlobMember);
 
 export default router;